@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/temporalio/features/harness/go/cmd"
+	"github.com/temporalio/features/harness/go/history"
+	"go.temporal.io/api/workflow/v1"
+	"go.temporal.io/sdk/client"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	BaselineOK   = "OK"
+	BaselineWarn = "WARN"
+	BaselineFail = "FAIL"
+	BaselineNew  = "NEW"
+)
+
+// BaselineFile is the on-disk form of a duration baseline. Durations are
+// recorded per language since the same feature is not comparable across SDKs,
+// and per SDK version so regressions can be traced to the version that
+// introduced them.
+type BaselineFile struct {
+	Langs map[string]*LangBaseline `json:"langs"`
+}
+
+// LangBaseline holds durations recorded for one SDK language.
+type LangBaseline struct {
+	// Versions holds durations keyed by the SDK version they were recorded
+	// with. Durations recorded without a known version are under the empty key.
+	Versions map[string]*VersionBaseline `json:"versions"`
+}
+
+// VersionBaseline holds durations recorded with one SDK version.
+type VersionBaseline struct {
+	Features map[string]FeatureBaseline `json:"features"`
+}
+
+// FeatureBaseline is the recorded duration of a single feature, keyed by its
+// summary name.
+type FeatureBaseline struct {
+	DurationMs int64 `json:"durationMs"`
+}
+
+// BaselineThresholds decide when a feature that got slower is flagged. A
+// slowdown is only flagged if it is at least MinSlowdown in absolute terms and
+// exceeds the given ratio of the baseline.
+type BaselineThresholds struct {
+	WarnRatio   float64
+	FailRatio   float64
+	MinSlowdown time.Duration
+}
+
+// BaselineResult is the comparison of one feature's duration against the
+// baseline.
+type BaselineResult struct {
+	Name     string
+	Duration time.Duration
+	Baseline time.Duration
+	// BaselineVersion is the SDK version the baseline was recorded with.
+	BaselineVersion string
+	Outcome         string
+}
+
+// LoadBaselineFile loads the baseline from the given file. A missing file
+// results in an empty baseline.
+func LoadBaselineFile(path string) (*BaselineFile, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &BaselineFile{Langs: map[string]*LangBaseline{}}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed reading baseline: %w", err)
+	}
+	var file BaselineFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling baseline %v: %w", path, err)
+	}
+	if file.Langs == nil {
+		file.Langs = map[string]*LangBaseline{}
+	}
+	return &file, nil
+}
+
+// Store writes the baseline to the given file.
+func (b *BaselineFile) Store(path string) error {
+	bytes, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshaling baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(bytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed writing baseline %v: %w", path, err)
+	}
+	return nil
+}
+
+// Record sets the durations for the given language and SDK version,
+// overwriting any prior value for the same features and version. Features not
+// present in durations and other versions are kept.
+func (b *BaselineFile) Record(lang, version string, durations map[string]time.Duration) {
+	langBaseline := b.Langs[lang]
+	if langBaseline == nil {
+		langBaseline = &LangBaseline{}
+		b.Langs[lang] = langBaseline
+	}
+	if langBaseline.Versions == nil {
+		langBaseline.Versions = map[string]*VersionBaseline{}
+	}
+	versionBaseline := langBaseline.Versions[version]
+	if versionBaseline == nil {
+		versionBaseline = &VersionBaseline{}
+		langBaseline.Versions[version] = versionBaseline
+	}
+	if versionBaseline.Features == nil {
+		versionBaseline.Features = map[string]FeatureBaseline{}
+	}
+	for name, duration := range durations {
+		versionBaseline.Features[name] = FeatureBaseline{DurationMs: duration.Milliseconds()}
+	}
+}
+
+// Compare compares the given durations of the given SDK version against the
+// baseline for the language. Each feature is compared with its duration
+// recorded for the same version or, if none, the latest earlier version that
+// recorded it. If the version is empty or not semver, it is compared with the
+// latest version that recorded it. Results are sorted by feature name.
+func (b *BaselineFile) Compare(
+	lang string,
+	version string,
+	durations map[string]time.Duration,
+	thresholds BaselineThresholds,
+) []BaselineResult {
+	var candidates []string
+	if langBaseline := b.Langs[lang]; langBaseline != nil {
+		candidates = langBaseline.candidateVersions(version)
+	}
+	results := make([]BaselineResult, 0, len(durations))
+	for name, duration := range durations {
+		result := BaselineResult{Name: name, Duration: duration, Outcome: BaselineNew}
+		for _, candidate := range candidates {
+			if existing, ok := b.Langs[lang].Versions[candidate].Features[name]; ok {
+				result.Baseline = time.Duration(existing.DurationMs) * time.Millisecond
+				result.BaselineVersion = candidate
+				result.Outcome = thresholds.outcome(duration, result.Baseline)
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+// candidateVersions returns the recorded versions to compare the given version
+// against, in order of preference: the same version, then semver versions
+// before it from newest to oldest.
+func (l *LangBaseline) candidateVersions(version string) []string {
+	var candidates, earlier []string
+	if versionBaseline := l.Versions[version]; versionBaseline != nil {
+		candidates = append(candidates, version)
+	}
+	current := semverVersion(version)
+	for recorded := range l.Versions {
+		if recorded == version || !semver.IsValid(semverVersion(recorded)) {
+			continue
+		} else if !semver.IsValid(current) || semver.Compare(semverVersion(recorded), current) < 0 {
+			earlier = append(earlier, recorded)
+		}
+	}
+	sort.Slice(earlier, func(i, j int) bool {
+		return semver.Compare(semverVersion(earlier[i]), semverVersion(earlier[j])) > 0
+	})
+	return append(candidates, earlier...)
+}
+
+func (t BaselineThresholds) outcome(duration, baseline time.Duration) string {
+	if duration-baseline < t.MinSlowdown || baseline <= 0 {
+		return BaselineOK
+	}
+	ratio := float64(duration) / float64(baseline)
+	if t.FailRatio > 0 && ratio >= t.FailRatio {
+		return BaselineFail
+	} else if t.WarnRatio > 0 && ratio >= t.WarnRatio {
+		return BaselineWarn
+	}
+	return BaselineOK
+}
+
+// Ratio is the current duration divided by the baseline, or 0 if there is no
+// baseline.
+func (b BaselineResult) Ratio() float64 {
+	if b.Baseline <= 0 {
+		return 0
+	}
+	return float64(b.Duration) / float64(b.Baseline)
+}
+
+// collectFeatureDurations records how long each passed feature's workflows took
+// on the server, from the earliest start to the latest close on the feature's
+// task queue. Measuring on the server keeps durations comparable across
+// languages regardless of what their harness reports.
+func (r *Runner) collectFeatureDurations(ctx context.Context, run *cmd.Run, summary Summary) (err error) {
+	ctx, span := tracer.Start(ctx, "collectFeatureDurations")
+	defer func() { endSpan(span, err) }()
+
+	cl, err := r.dialClient()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	// Only the first of repeated runs is measured, and only if all of them
+	// passed since which one failed is not known
+	measured := map[string]bool{}
+	for _, feature := range run.Features {
+		if feature.Config.NoWorkflow || measured[feature.SummaryName()] {
+			continue
+		}
+		measured[feature.SummaryName()] = true
+		if entry, ok := summary.FindWorst(feature.SummaryName()); !ok || entry.Outcome != FeaturePassed {
+			continue
+		}
+		if duration, ok, err := r.featureDuration(ctx, cl, feature); err != nil {
+			return err
+		} else if ok {
+			r.featureDurations[feature.SummaryName()] = duration
+		} else {
+			r.log.Warn("No closed workflows found to measure feature duration", "Feature", feature.SummaryName())
+		}
+	}
+	return nil
+}
+
+// featureDuration returns how long the feature's workflows took on the server,
+// or false if none of them have closed.
+func (r *Runner) featureDuration(ctx context.Context, cl client.Client, feature cmd.RunFeature) (time.Duration, bool, error) {
+	fetcher := history.Fetcher{
+		Client:            cl,
+		Namespace:         r.config.Namespace,
+		TaskQueue:         feature.TaskQueue,
+		TaskQueueSuffixes: r.featureTaskQueueSuffixes(feature.Dir),
+		FeatureStarted:    r.createTime,
+	}
+	execs, err := fetcher.GetExecutions(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed getting executions for %v: %w", feature.SummaryName(), err)
+	}
+	duration, ok := executionsDuration(execs)
+	return duration, ok, nil
+}
+
+// executionsDuration returns the time from the earliest start to the latest
+// close of the given executions, ignoring any still open.
+func executionsDuration(execs []*workflow.WorkflowExecutionInfo) (time.Duration, bool) {
+	var earliest, latest time.Time
+	for _, exec := range execs {
+		if exec.GetStartTime() == nil || exec.GetCloseTime() == nil {
+			continue
+		}
+		start, closed := exec.GetStartTime().AsTime(), exec.GetCloseTime().AsTime()
+		if earliest.IsZero() || start.Before(earliest) {
+			earliest = start
+		}
+		if closed.After(latest) {
+			latest = closed
+		}
+	}
+	if earliest.IsZero() || latest.IsZero() {
+		return 0, false
+	}
+	return latest.Sub(earliest), true
+}
+
+// checkBaseline compares collected feature durations against the configured
+// baseline, reporting each feature, and optionally records them back into the
+// baseline file. It returns an error if any feature slowed down enough to
+// fail.
+func (r *Runner) checkBaseline() error {
+	baseline, err := LoadBaselineFile(r.config.BaselineFile)
+	if err != nil {
+		return err
+	}
+	version := r.currentVersion()
+	results := baseline.Compare(r.config.Lang, version, r.featureDurations, r.config.BaselineThresholds)
+	var failed []string
+	for _, result := range results {
+		if result.Outcome == BaselineNew {
+			fmt.Printf("Feature duration feature=%s duration=%v outcome=%s\n",
+				result.Name, result.Duration, result.Outcome)
+		} else {
+			fmt.Printf("Feature duration feature=%s duration=%v baseline=%v baselineVersion=%s ratio=%.2f outcome=%s\n",
+				result.Name, result.Duration, result.Baseline, result.BaselineVersion, result.Ratio(), result.Outcome)
+		}
+		switch result.Outcome {
+		case BaselineWarn:
+			r.log.Warn("Feature slower than baseline", "Feature", result.Name,
+				"Duration", result.Duration, "Baseline", result.Baseline)
+		case BaselineFail:
+			r.log.Error("Feature much slower than baseline", "Feature", result.Name,
+				"Duration", result.Duration, "Baseline", result.Baseline)
+			failed = append(failed, result.Name)
+		}
+	}
+	if r.config.WriteBaseline {
+		baseline.Record(r.config.Lang, version, r.featureDurations)
+		if err := baseline.Store(r.config.BaselineFile); err != nil {
+			return err
+		}
+		r.log.Info("Wrote duration baseline", "File", r.config.BaselineFile, "Features", len(r.featureDurations))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%v feature(s) slower than baseline: %v", len(failed), failed)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBaselineCompareFlagsSlowdowns(t *testing.T) {
+	baseline := &BaselineFile{Langs: map[string]*LangBaseline{}}
+	baseline.Record("go", "v1.30.0", map[string]time.Duration{
+		"local_activity/basic":  1 * time.Second,
+		"update/basic":          1 * time.Second,
+		"update/async_accepted": 1 * time.Second,
+		"timer/basic":           10 * time.Millisecond,
+	})
+	thresholds := BaselineThresholds{WarnRatio: 1.5, FailRatio: 3, MinSlowdown: 100 * time.Millisecond}
+	results := baseline.Compare("go", "v1.30.0", map[string]time.Duration{
+		"local_activity/basic":  1100 * time.Millisecond,
+		"update/basic":          2 * time.Second,
+		"update/async_accepted": 4 * time.Second,
+		// Large ratio but below the minimum slowdown
+		"timer/basic":  50 * time.Millisecond,
+		"signal/basic": 1 * time.Second,
+	}, thresholds)
+
+	want := map[string]string{
+		"local_activity/basic":  BaselineOK,
+		"update/basic":          BaselineWarn,
+		"update/async_accepted": BaselineFail,
+		"timer/basic":           BaselineOK,
+		"signal/basic":          BaselineNew,
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for _, result := range results {
+		if result.Outcome != want[result.Name] {
+			t.Fatalf("feature %v outcome = %v, want %v", result.Name, result.Outcome, want[result.Name])
+		}
+	}
+}
+
+func TestBaselineFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	baseline, err := LoadBaselineFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline.Record("go", "v1.30.0", map[string]time.Duration{"activity/basic": 1500 * time.Millisecond})
+	if err := baseline.Store(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBaselineFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := loaded.Langs["go"].Versions["v1.30.0"]
+	if got == nil || got.Features["activity/basic"].DurationMs != 1500 {
+		t.Fatalf("unexpected loaded baseline: %+v", loaded.Langs["go"])
+	}
+}
+
+func TestBaselineCompareUsesMatchingOrLatestEarlierVersion(t *testing.T) {
+	baseline := &BaselineFile{Langs: map[string]*LangBaseline{}}
+	baseline.Record("go", "v1.28.0", map[string]time.Duration{
+		"update/basic": 1 * time.Second,
+		"timer/basic":  1 * time.Second,
+	})
+	baseline.Record("go", "v1.29.0", map[string]time.Duration{"update/basic": 2 * time.Second})
+	baseline.Record("go", "v1.31.0", map[string]time.Duration{"update/basic": 3 * time.Second})
+	// Recording a later version must not replace the earlier ones
+	if len(baseline.Langs["go"].Versions) != 3 {
+		t.Fatalf("got versions %v, want 3", baseline.Langs["go"].Versions)
+	}
+	durations := map[string]time.Duration{"update/basic": 2 * time.Second, "timer/basic": 1 * time.Second}
+	thresholds := BaselineThresholds{WarnRatio: 1.5, FailRatio: 3}
+
+	tests := []struct {
+		version string
+		want    map[string]string
+	}{
+		// Exact version, and earlier version for features it did not record
+		{"v1.29.0", map[string]string{"update/basic": "v1.29.0", "timer/basic": "v1.28.0"}},
+		// Latest earlier version, never a later one
+		{"1.30.0", map[string]string{"update/basic": "v1.29.0", "timer/basic": "v1.28.0"}},
+		{"v1.27.0", map[string]string{"update/basic": "", "timer/basic": ""}},
+		// Unknown version compares with the latest recorded
+		{"", map[string]string{"update/basic": "v1.31.0", "timer/basic": "v1.28.0"}},
+	}
+	for _, tt := range tests {
+		for _, result := range baseline.Compare("go", tt.version, durations, thresholds) {
+			if result.BaselineVersion != tt.want[result.Name] {
+				t.Fatalf("version %q feature %v compared with %q, want %q",
+					tt.version, result.Name, result.BaselineVersion, tt.want[result.Name])
+			}
+		}
+	}
+}
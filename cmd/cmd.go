@@ -31,6 +31,8 @@ func NewApp() *cli.App {
 			buildImageCmd(),
 			publishImageCmd(),
 			latestSdkVersionCmd(),
+			historyCmd(),
+			replayCmd(),
 		},
 	}
 }
@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/temporalio/features/harness/go/cmd"
+	"github.com/temporalio/features/harness/go/history"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
+)
+
+func historyCmd() *cli.Command {
+	var config HistoryConfig
+	formatFlag := func(usage string, required bool) cli.Flag {
+		return &cli.StringFlag{
+			Name:        "format",
+			Usage:       usage + ": \"json\", \"json.gz\", or \"binpb\"",
+			Required:    required,
+			Destination: &config.Format,
+		}
+	}
+	return &cli.Command{
+		Name:  "history",
+		Usage: "Manage stored history files",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "convert",
+				Usage:     "Convert stored history files to another format",
+				ArgsUsage: "[feature patterns...]",
+				Flags:     append(config.flags(), formatFlag("Format to convert to", true)),
+				Action: func(ctx *cli.Context) error {
+					return NewHistoryManager(config).Convert(ctx.Args().Slice())
+				},
+			},
+			{
+				Name:      "migrate",
+				Usage:     "Re-serialize stored history files with the current API",
+				ArgsUsage: "[feature patterns...]",
+				Flags: append(config.flags(),
+					formatFlag("Format to write in, default is each file's current format", false),
+					&cli.BoolFlag{
+						Name:        "scrub",
+						Usage:       "Also remove fields in the feature's historyCompare scrub rules",
+						Destination: &config.Scrub,
+					},
+				),
+				Action: func(ctx *cli.Context) error {
+					return NewHistoryManager(config).Migrate(ctx.Args().Slice())
+				},
+			},
+			{
+				Name:      "prune",
+				Usage:     "Remove all but the newest stored history versions per language",
+				ArgsUsage: "[feature patterns...]",
+				Flags: append(config.flags(),
+					&cli.IntFlag{
+						Name:        "keep",
+						Usage:       "Number of newest versions to keep per feature and language",
+						Required:    true,
+						Destination: &config.Keep,
+					},
+					&cli.BoolFlag{
+						Name:        "dry-run",
+						Usage:       "Only log the files that would be removed",
+						Destination: &config.DryRun,
+					},
+				),
+				Action: func(ctx *cli.Context) error {
+					return NewHistoryManager(config).Prune(ctx.Args().Slice())
+				},
+			},
+			{
+				Name:      "stats",
+				Usage:     "Show event counts and size of stored history files",
+				ArgsUsage: "[feature patterns...]",
+				Flags:     config.flags(),
+				Action: func(ctx *cli.Context) error {
+					return NewHistoryManager(config).Stats(ctx.Args().Slice())
+				},
+			},
+			{
+				Name:      "render",
+				Usage:     "Render a history file as a timeline of each execution",
+				ArgsUsage: "<file>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "format",
+						Usage:       "Output format: \"text\", \"mermaid\", or \"html\"",
+						Value:       history.RenderText,
+						Destination: &config.RenderFormat,
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.NArg() != 1 {
+						return fmt.Errorf("exactly one history file required")
+					}
+					return NewHistoryManager(config).Render(ctx.Args().First(), os.Stdout)
+				},
+			},
+		},
+	}
+}
+
+// HistoryConfig is configuration for NewHistoryManager.
+type HistoryConfig struct {
+	// Language whose history files are managed. All languages if empty.
+	Lang string
+	// Format used when writing history files.
+	Format string
+	// Whether migrate also applies scrub rules to histories.
+	Scrub bool
+	// Number of versions prune keeps.
+	Keep int
+	// Whether prune only logs.
+	DryRun bool
+	// Format render writes in.
+	RenderFormat string
+}
+
+func (h *HistoryConfig) flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "lang",
+			Usage:       "SDK language whose history is managed ('go' or 'java' or 'ts' or 'py' or 'cs' or 'rb'), default all",
+			Destination: &h.Lang,
+		},
+	}
+}
+
+// HistoryManager manages stored history files of features.
+type HistoryManager struct {
+	config  HistoryConfig
+	rootDir string
+}
+
+// NewHistoryManager creates a new history manager.
+func NewHistoryManager(config HistoryConfig) *HistoryManager {
+	return &HistoryManager{
+		config:  config,
+		rootDir: rootDir(),
+	}
+}
+
+// Convert rewrites every stored history file of the features matching the
+// patterns, or all features if none, that is not in the configured format.
+func (h *HistoryManager) Convert(patterns []string) error {
+	if err := history.ValidateFormat(h.config.Format); err != nil {
+		return err
+	}
+	storages, err := h.storages(patterns)
+	if err != nil {
+		return err
+	}
+	for _, storage := range storages {
+		files, err := storage.Files()
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file.Format == storage.Format {
+				continue
+			}
+			hist, err := history.ReadFile(file.Path, file.Format)
+			if err != nil {
+				return err
+			}
+			fileStorage := *storage
+			fileStorage.Variant = file.Variant
+			set := &history.StoredSet{ByVersion: map[string]history.Histories{file.Version: hist}}
+			if err := fileStorage.Store(set); err != nil {
+				return err
+			}
+			fmt.Printf("Converted history from=%s to=%s\n", h.relPath(file.Path), h.relPath(fileStorage.File(file.Version)))
+		}
+	}
+	return nil
+}
+
+// Migrate reads and rewrites every stored history file of the features matching
+// the patterns, or all features if none. Files are rewritten in the configured
+// format, or their current format if not set.
+func (h *HistoryManager) Migrate(patterns []string) error {
+	if err := history.ValidateFormat(h.config.Format); err != nil {
+		return err
+	}
+	storages, err := h.storages(patterns)
+	if err != nil {
+		return err
+	}
+	for _, storage := range storages {
+		files, err := storage.Files()
+		if err != nil {
+			return err
+		}
+		var featureConfig cmd.RunFeatureConfig
+		if h.config.Scrub && len(files) > 0 {
+			if err := featureConfig.LoadFromDir(filepath.Dir(storage.Dir)); err != nil {
+				return fmt.Errorf("failed reading config for %v: %w", storage.Dir, err)
+			}
+		}
+		for _, file := range files {
+			hist, err := history.ReadFile(file.Path, file.Format)
+			if err != nil {
+				return err
+			}
+			// Run-specific fields are kept since executions are paired by the
+			// relationships they record
+			if h.config.Scrub {
+				if err := hist.ApplyScrubRulesKeepingRelationships(featureConfig.HistoryCompare.Scrub); err != nil {
+					return fmt.Errorf("failed scrubbing %v: %w", file.Path, err)
+				}
+			}
+			fileStorage := *storage
+			fileStorage.Variant = file.Variant
+			if fileStorage.Format == "" {
+				fileStorage.Format = file.Format
+			}
+			set := &history.StoredSet{ByVersion: map[string]history.Histories{file.Version: hist}}
+			if err := fileStorage.Store(set); err != nil {
+				return err
+			}
+			fmt.Printf("Migrated history from=%s to=%s\n", h.relPath(file.Path), h.relPath(fileStorage.File(file.Version)))
+		}
+	}
+	return nil
+}
+
+// Prune removes all but the newest Keep versions of each language's and run
+// variant's stored history files of the features matching the patterns, or all
+// features if none. Files whose version is not semver are never removed.
+func (h *HistoryManager) Prune(patterns []string) error {
+	if h.config.Keep < 1 {
+		return fmt.Errorf("must keep at least one version")
+	}
+	storages, err := h.storages(patterns)
+	if err != nil {
+		return err
+	}
+	for _, storage := range storages {
+		files, err := storage.Files()
+		if err != nil {
+			return err
+		}
+		for _, file := range filesToPrune(files, h.config.Keep) {
+			if h.config.DryRun {
+				fmt.Printf("Would remove history file=%s\n", h.relPath(file.Path))
+				continue
+			} else if err := os.Remove(file.Path); err != nil {
+				return fmt.Errorf("failed removing %v: %w", file.Path, err)
+			}
+			fmt.Printf("Removed history file=%s\n", h.relPath(file.Path))
+		}
+	}
+	return nil
+}
+
+// filesToPrune returns the files with semver versions older than the newest
+// keep of them of the same variant.
+func filesToPrune(files []*history.StoredFile, keep int) []*history.StoredFile {
+	var variants []string
+	versioned := map[string][]*history.StoredFile{}
+	for _, file := range files {
+		if semver.IsValid(semverVersion(file.Version)) {
+			if _, ok := versioned[file.Variant]; !ok {
+				variants = append(variants, file.Variant)
+			}
+			versioned[file.Variant] = append(versioned[file.Variant], file)
+		}
+	}
+	var prune []*history.StoredFile
+	for _, variant := range variants {
+		variantFiles := versioned[variant]
+		if len(variantFiles) <= keep {
+			continue
+		}
+		sort.Slice(variantFiles, func(i, j int) bool {
+			return semver.Compare(semverVersion(variantFiles[i].Version), semverVersion(variantFiles[j].Version)) > 0
+		})
+		prune = append(prune, variantFiles[keep:]...)
+	}
+	return prune
+}
+
+// semverVersion adds the "v" prefix semver requires, which history versions of
+// languages other than Go do not have.
+func semverVersion(version string) string {
+	if !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
+
+// Stats prints the format, size, and number of histories and events of every
+// stored history file of the features matching the patterns, or all features
+// if none.
+func (h *HistoryManager) Stats(patterns []string) error {
+	storages, err := h.storages(patterns)
+	if err != nil {
+		return err
+	}
+	for _, storage := range storages {
+		files, err := storage.Files()
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			info, err := os.Stat(file.Path)
+			if err != nil {
+				return err
+			}
+			hist, err := history.ReadFile(file.Path, file.Format)
+			if err != nil {
+				return err
+			}
+			events := 0
+			for _, h := range hist {
+				events += len(h.Events)
+			}
+			fmt.Printf("History stats file=%s format=%s size=%d histories=%d events=%d\n",
+				h.relPath(file.Path), file.Format, info.Size(), len(hist), events)
+		}
+	}
+	return nil
+}
+
+// Render writes the executions of the history file to the writer in the
+// configured render format.
+func (h *HistoryManager) Render(file string, w io.Writer) error {
+	hist, err := history.ReadFile(file, history.FileFormat(file))
+	if err != nil {
+		return err
+	}
+	if err := hist.Render(w, h.config.RenderFormat); err != nil {
+		return fmt.Errorf("failed rendering %v: %w", file, err)
+	}
+	return nil
+}
+
+// relPath returns the /-slashed path relative to the root for display.
+func (h *HistoryManager) relPath(path string) string {
+	if rel, err := filepath.Rel(h.rootDir, path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// storages returns a storage in the configured format for each history
+// directory and language of the features matching the patterns, or all
+// features if none.
+func (h *HistoryManager) storages(patterns []string) ([]*history.Storage, error) {
+	langs := history.Languages
+	if h.config.Lang != "" {
+		lang, err := normalizeLangName(h.config.Lang)
+		if err != nil {
+			return nil, err
+		}
+		langs = []string{lang}
+	}
+	dirs, err := h.globHistoryDirs(patterns)
+	if err != nil {
+		return nil, err
+	}
+	var storages []*history.Storage
+	for _, dir := range dirs {
+		for _, lang := range langs {
+			storages = append(storages, &history.Storage{Dir: dir, Lang: lang, Format: h.config.Format})
+		}
+	}
+	return storages, nil
+}
+
+// globHistoryDirs returns the history directory of every feature matching the
+// patterns, or all features if none, that has one.
+func (h *HistoryManager) globHistoryDirs(patterns []string) ([]string, error) {
+	var dirs []string
+	featuresDir := filepath.Join(h.rootDir, "features")
+	err := filepath.WalkDir(featuresDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if !entry.IsDir() || entry.Name() != "history" {
+			return nil
+		}
+
+		// Get relative, /-slashed, feature dir
+		dir, err := filepath.Rel(featuresDir, filepath.Dir(path))
+		if err != nil {
+			// Should never happen
+			return err
+		}
+		dir = filepath.ToSlash(dir)
+
+		// If no patterns present, all match
+		foundMatch := len(patterns) == 0
+		for _, pattern := range patterns {
+			match, err := filepath.Match(strings.TrimPrefix(pattern, "features/"), dir)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			} else if match {
+				foundMatch = true
+				break
+			}
+		}
+		if foundMatch {
+			dirs = append(dirs, path)
+		}
+		return filepath.SkipDir
+	})
+	return dirs, err
+}
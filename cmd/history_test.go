@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestHistoryPruneKeepsNewestVersions(t *testing.T) {
+	rootDir := t.TempDir()
+	historyDir := filepath.Join(rootDir, "features", "activity", "basic", "history")
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"history.go.v1.9.0.json",
+		"history.go.v1.10.0.json",
+		"history.go.v1.2.0.binpb",
+		"history.go.enabled.v1.2.0.json",
+		"history.go.enabled.v1.3.0.json",
+		"history.java.1.0.0.json",
+		"history.manual.json",
+	} {
+		if err := os.WriteFile(filepath.Join(historyDir, name), []byte("[]"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager := &HistoryManager{config: HistoryConfig{Keep: 1}, rootDir: rootDir}
+	if err := manager.Prune([]string{"activity/*"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(historyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	sort.Strings(remaining)
+	// Variants keep their own newest versions
+	expected := []string{
+		"history.go.enabled.v1.3.0.json",
+		"history.go.v1.10.0.json",
+		"history.java.1.0.0.json",
+		"history.manual.json",
+	}
+	if len(remaining) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, remaining)
+	}
+	for i := range expected {
+		if remaining[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, remaining)
+		}
+	}
+}
@@ -150,37 +150,47 @@ var registryQueries = map[string]registryQuery{
 }
 
 func getLatestSdkVersion(config LatestSdkVersionConfig) error {
-	lang, err := expandLangName(config.Lang)
+	version, err := latestSdkVersion(config.Lang)
 	if err != nil {
 		return err
 	}
 
+	fmt.Println(strings.TrimPrefix(version, "v"))
+
+	return nil
+}
+
+// latestSdkVersion returns the latest version of the language's SDK as
+// published in its package registry.
+func latestSdkVersion(lang string) (string, error) {
+	lang, err := expandLangName(lang)
+	if err != nil {
+		return "", err
+	}
+
 	query, ok := registryQueries[lang]
 	if !ok {
-		return fmt.Errorf("no package registry configured for language %q", lang)
+		return "", fmt.Errorf("no package registry configured for language %q", lang)
 	}
 
 	resp, err := http.Get(query.url)
 	if err != nil {
-		return fmt.Errorf("failed to query package registry: %w", err)
+		return "", fmt.Errorf("failed to query package registry: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("package registry returned status %d for %s", resp.StatusCode, query.url)
+		return "", fmt.Errorf("package registry returned status %d for %s", resp.StatusCode, query.url)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return "", fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	version, err := query.extract(body)
 	if err != nil {
-		return fmt.Errorf("failed to extract version from response: %w", err)
+		return "", fmt.Errorf("failed to extract version from response: %w", err)
 	}
-
-	fmt.Println(strings.TrimPrefix(version, "v"))
-
-	return nil
+	return version, nil
 }
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/temporalio/features/harness/go/cmd"
+	"github.com/temporalio/features/harness/go/history"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/workflow/v1"
+	"go.temporal.io/sdk/client"
+)
+
+const (
+	LeakCheckOff  = "off"
+	LeakCheckWarn = "warn"
+	LeakCheckFail = "fail"
+)
+
+// leakCheckTaskQueueTypes are the task queue types checked for pollers. Every
+// worker polls for workflows or activities, so Nexus is not needed.
+var leakCheckTaskQueueTypes = []enums.TaskQueueType{
+	enums.TASK_QUEUE_TYPE_WORKFLOW,
+	enums.TASK_QUEUE_TYPE_ACTIVITY,
+}
+
+// leakPollInterval is how long after a feature finished a worker it left
+// running is sure to have polled again. It is a little over the 70 second long
+// poll timeout of the SDKs.
+var leakPollInterval = 75 * time.Second
+
+// FeatureLeaks are what a feature left behind on its task queues after it
+// finished.
+type FeatureLeaks struct {
+	Name string
+	// Workflows still running
+	Running []*workflow.WorkflowExecutionInfo
+	// Pollers that polled after the feature finished, including those that
+	// polled again after a long poll begun before it finished
+	Pollers []LeakedPoller
+}
+
+// LeakedPoller is a poller still seen on one of a feature's task queues.
+type LeakedPoller struct {
+	TaskQueue      string
+	TaskQueueType  enums.TaskQueueType
+	Identity       string
+	LastAccessTime time.Time
+}
+
+func (f *FeatureLeaks) empty() bool { return len(f.Running) == 0 && len(f.Pollers) == 0 }
+
+// leakCheck checks the features of a batch for workflows still running and
+// pollers still active on their task queues. Each feature is checked as soon as
+// its harness reports it finished, so leaks are reported, and cleaned up if
+// enabled, while the rest of the batch runs. Leaks are reported as warnings, or
+// as an error if the leak check is set to fail.
+type leakCheck struct {
+	r   *Runner
+	ctx context.Context
+	cl  client.Client
+	wg  sync.WaitGroup
+
+	lock sync.Mutex
+	// Features not yet checked, by summary name
+	pending map[string][]cmd.RunFeature
+	// Number of summary entries still expected, by summary name
+	unreported map[string]int
+	leaked     []string
+	errs       []error
+}
+
+// startLeakCheck returns a leak check for the features of the run, or nil if
+// the leak check is off.
+func (r *Runner) startLeakCheck(ctx context.Context, run *cmd.Run) (*leakCheck, error) {
+	if r.config.LeakCheck == LeakCheckOff {
+		return nil, nil
+	}
+	cl, err := r.dialClient()
+	if err != nil && r.config.LeakCheck != LeakCheckFail {
+		// Only a failing leak check fails the run, otherwise problems checking
+		// are just warnings like the leaks themselves
+		r.log.Warn("Leak check failed", "error", err)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return newLeakCheck(ctx, r, cl, run), nil
+}
+
+func newLeakCheck(ctx context.Context, r *Runner, cl client.Client, run *cmd.Run) *leakCheck {
+	l := &leakCheck{
+		r:          r,
+		ctx:        ctx,
+		cl:         cl,
+		pending:    map[string][]cmd.RunFeature{},
+		unreported: map[string]int{},
+	}
+	for _, feature := range run.Features {
+		l.pending[feature.SummaryName()] = append(l.pending[feature.SummaryName()], feature)
+		l.unreported[feature.SummaryName()]++
+	}
+	return l
+}
+
+// featureFinished checks the feature of the summary entry for leaks in the
+// background. Repetitions of a feature share its summary name and may finish
+// in any order across concurrent harness runs, so they are checked once all of
+// them have reported. Entries of no feature of the run are ignored.
+func (l *leakCheck) featureFinished(entry SummaryEntry) {
+	finished := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if features := l.reported(entry.Name); len(features) > 0 {
+		l.check(features, finished)
+	}
+}
+
+// reported records a summary entry of the given name and returns the features
+// now ready to be checked, if any. The lock must be held.
+func (l *leakCheck) reported(name string) []cmd.RunFeature {
+	if _, ok := l.unreported[name]; !ok {
+		return nil
+	}
+	l.unreported[name]--
+	if l.unreported[name] > 0 {
+		return nil
+	}
+	features := l.pending[name]
+	delete(l.pending, name)
+	delete(l.unreported, name)
+	return features
+}
+
+// check checks the features in the background. The lock must be held.
+func (l *leakCheck) check(features []cmd.RunFeature, finished time.Time) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		for _, feature := range features {
+			leaked, err := l.r.checkFeatureLeaks(l.ctx, l.cl, feature, finished)
+			l.lock.Lock()
+			if err != nil {
+				l.errs = append(l.errs, err)
+			} else if leaked {
+				l.leaked = append(l.leaked, feature.SummaryName())
+			}
+			l.lock.Unlock()
+		}
+	}()
+}
+
+// finish checks the features never reported, such as those of a harness that
+// failed or was stopped, then waits for all checks. If the leak check is set
+// to fail, it returns an error if any check failed or any feature leaked,
+// otherwise failed checks are only logged.
+func (l *leakCheck) finish(harnessFinished time.Time) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	for _, features := range l.pending {
+		l.check(features, harnessFinished)
+	}
+	l.pending, l.unreported = nil, nil
+	l.lock.Unlock()
+	l.wg.Wait()
+	l.cl.Close()
+	if l.r.config.LeakCheck != LeakCheckFail {
+		if err := errors.Join(l.errs...); err != nil {
+			l.r.log.Warn("Leak check failed", "error", err)
+		}
+		return nil
+	}
+	errs := l.errs
+	if len(l.leaked) > 0 {
+		sort.Strings(l.leaked)
+		errs = append(errs, fmt.Errorf("%v feature(s) left running workflows or pollers: %v", len(l.leaked), l.leaked))
+	}
+	return errors.Join(errs...)
+}
+
+// checkFeatureLeaks looks for what the feature left behind once it finished,
+// reporting and, if cleanup is enabled, terminating leaked workflows. It
+// returns whether anything leaked.
+func (r *Runner) checkFeatureLeaks(
+	ctx context.Context,
+	cl client.Client,
+	feature cmd.RunFeature,
+	finished time.Time,
+) (leaked bool, err error) {
+	ctx, span := tracer.Start(ctx, "checkFeatureLeaks", trace.WithAttributes(
+		attribute.String("feature", feature.SummaryName()),
+	))
+	defer func() { endSpan(span, err) }()
+
+	leaks, err := r.findFeatureLeaks(ctx, cl, feature, finished)
+	if err != nil {
+		return false, err
+	} else if leaks.empty() {
+		return false, nil
+	}
+	r.reportFeatureLeaks(leaks)
+	if r.config.LeakCleanup {
+		for _, exec := range leaks.Running {
+			err := cl.TerminateWorkflow(ctx, exec.Execution.WorkflowId, exec.Execution.RunId,
+				"left running by feature "+leaks.Name)
+			if err != nil {
+				r.log.Error("Failed terminating leaked workflow", "Feature", leaks.Name,
+					"WorkflowID", exec.Execution.WorkflowId, "RunID", exec.Execution.RunId, "error", err)
+			} else {
+				r.log.Info("Terminated leaked workflow", "Feature", leaks.Name,
+					"WorkflowID", exec.Execution.WorkflowId, "RunID", exec.Execution.RunId)
+			}
+		}
+	}
+	return true, nil
+}
+
+func (r *Runner) findFeatureLeaks(
+	ctx context.Context,
+	cl client.Client,
+	feature cmd.RunFeature,
+	finished time.Time,
+) (*FeatureLeaks, error) {
+	leaks := &FeatureLeaks{Name: feature.SummaryName()}
+	fetcher := history.Fetcher{
+		Client:            cl,
+		Namespace:         r.config.Namespace,
+		TaskQueue:         feature.TaskQueue,
+		TaskQueueSuffixes: r.featureTaskQueueSuffixes(feature.Dir),
+		FeatureStarted:    r.createTime,
+	}
+	// Visibility can still show workflows as running shortly after they have
+	// completed, so only those still running after a while are leaked, like
+	// Fetcher.Fetch waits for them
+	const maxRunningWait = 5 * time.Second
+	for start := time.Now(); ; {
+		execs, err := fetcher.GetExecutions(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed getting executions for %v: %w", leaks.Name, err)
+		}
+		leaks.Running = leaks.Running[:0]
+		for _, exec := range execs {
+			// Workflows marked as ignored are intentionally left open
+			if exec.Status == enums.WORKFLOW_EXECUTION_STATUS_RUNNING && !history.IsIgnored(exec) {
+				leaks.Running = append(leaks.Running, exec)
+			}
+		}
+		if len(leaks.Running) == 0 || time.Since(start) >= maxRunningWait {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	// The server remembers pollers for a while after they stop, so a poller that
+	// polled after the feature finished is alive. One that last polled before
+	// may still be in a long poll begun before the feature finished, so it is
+	// only gone if it has not polled again one poll interval after.
+	taskQueues := fetcher.TaskQueues()
+	pollers, err := describePollers(ctx, cl, taskQueues)
+	if err != nil {
+		return nil, fmt.Errorf("failed finding pollers for %v: %w", leaks.Name, err)
+	}
+	var polledBeforeFinish bool
+	for _, poller := range pollers {
+		polledBeforeFinish = polledBeforeFinish || !poller.LastAccessTime.After(finished)
+	}
+	if polledBeforeFinish {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Until(finished.Add(leakPollInterval))):
+		}
+		later, err := describePollers(ctx, cl, taskQueues)
+		if err != nil {
+			return nil, fmt.Errorf("failed finding pollers for %v: %w", leaks.Name, err)
+		}
+		for key, poller := range later {
+			pollers[key] = poller
+		}
+	}
+	for _, poller := range pollers {
+		if poller.LastAccessTime.After(finished) {
+			leaks.Pollers = append(leaks.Pollers, poller)
+		}
+	}
+	sort.Slice(leaks.Pollers, func(i, j int) bool {
+		a, b := leaks.Pollers[i], leaks.Pollers[j]
+		if a.TaskQueue != b.TaskQueue {
+			return a.TaskQueue < b.TaskQueue
+		} else if a.TaskQueueType != b.TaskQueueType {
+			return a.TaskQueueType < b.TaskQueueType
+		}
+		return a.Identity < b.Identity
+	})
+	return leaks, nil
+}
+
+type pollerKey struct {
+	taskQueue     string
+	taskQueueType enums.TaskQueueType
+	identity      string
+}
+
+// describePollers returns the pollers the server knows of on the task queues.
+func describePollers(ctx context.Context, cl client.Client, taskQueues []string) (map[pollerKey]LeakedPoller, error) {
+	pollers := map[pollerKey]LeakedPoller{}
+	for _, taskQueue := range taskQueues {
+		for _, taskQueueType := range leakCheckTaskQueueTypes {
+			resp, err := cl.DescribeTaskQueue(ctx, taskQueue, taskQueueType)
+			if err != nil {
+				return nil, fmt.Errorf("failed describing task queue %v: %w", taskQueue, err)
+			}
+			for _, poller := range resp.Pollers {
+				pollers[pollerKey{taskQueue, taskQueueType, poller.Identity}] = LeakedPoller{
+					TaskQueue:      taskQueue,
+					TaskQueueType:  taskQueueType,
+					Identity:       poller.Identity,
+					LastAccessTime: poller.GetLastAccessTime().AsTime(),
+				}
+			}
+		}
+	}
+	return pollers, nil
+}
+
+func (r *Runner) reportFeatureLeaks(leaks *FeatureLeaks) {
+	for _, exec := range leaks.Running {
+		fmt.Printf("Feature leak feature=%s kind=workflow type=%s workflowId=%s runId=%s\n",
+			leaks.Name, exec.GetType().GetName(), exec.Execution.WorkflowId, exec.Execution.RunId)
+	}
+	for _, poller := range leaks.Pollers {
+		fmt.Printf("Feature leak feature=%s kind=poller taskQueue=%s taskQueueType=%s identity=%s lastAccess=%s\n",
+			leaks.Name, poller.TaskQueue, poller.TaskQueueType, poller.Identity, poller.LastAccessTime.Format(time.RFC3339Nano))
+	}
+	r.log.Warn("Feature left workflows or pollers behind", "Feature", leaks.Name,
+		"RunningWorkflows", len(leaks.Running), "Pollers", len(leaks.Pollers))
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/temporalio/features/harness/go/harness"
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.temporal.io/sdk/log"
 )
 
@@ -26,9 +28,10 @@ func prepareCmd() *cli.Command {
 
 // PrepareConfig is configuration for NewPreparer.
 type PrepareConfig struct {
-	DirName string
-	Lang    string
-	Version string
+	DirName   string
+	Lang      string
+	Version   string
+	TraceFile string
 }
 
 func (p *PrepareConfig) flags() []cli.Flag {
@@ -46,6 +49,11 @@ func (p *PrepareConfig) flags() []cli.Flag {
 			Usage:       "SDK language version to run. Most languages support versions as paths.",
 			Destination: &p.Version,
 		},
+		&cli.StringFlag{
+			Name:        "trace-file",
+			Usage:       "File to write OpenTelemetry spans of the build steps to, in OTLP-JSON form (optional)",
+			Destination: &p.TraceFile,
+		},
 	}
 }
 
@@ -64,8 +72,21 @@ func NewPreparer(config PrepareConfig) *Preparer {
 	}
 }
 
-func (p *Preparer) Prepare(ctx context.Context) error {
-	var err error
+func (p *Preparer) Prepare(ctx context.Context) (err error) {
+	if p.config.TraceFile != "" {
+		stopTracing, err := startTracing(ctx, p.config.TraceFile)
+		if err != nil {
+			return err
+		}
+		defer stopTracing()
+	}
+	ctx, span := tracer.Start(ctx, "Prepare", trace.WithAttributes(
+		attribute.String("lang", p.config.Lang),
+		attribute.String("version", p.config.Version),
+		attribute.String("dir", p.config.DirName),
+	))
+	defer func() { endSpan(span, err) }()
+
 	if p.config.Lang, err = normalizeLangName(p.config.Lang); err != nil {
 		return err
 	} else if p.config.DirName == "" {
@@ -100,3 +121,12 @@ func (p *Preparer) Prepare(ctx context.Context) error {
 	}
 	return err
 }
+
+// startBuildSpan starts a span for a single SDK build step.
+func (p *Preparer) startBuildSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("lang", p.config.Lang),
+		attribute.String("version", p.config.Version),
+		attribute.String("dir", p.config.DirName),
+	))
+}
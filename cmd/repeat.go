@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/temporalio/features/harness/go/cmd"
+)
+
+// RepeatStats are the outcomes of all repeated runs of one feature.
+type RepeatStats struct {
+	Name    string
+	Passed  int
+	Failed  int
+	Skipped int
+	// Distinct failure messages, most frequent first
+	Failures []RepeatFailure
+	// Sorted durations of the runs whose workflows closed
+	Durations []time.Duration
+}
+
+// RepeatFailure is a distinct failure message and how many runs failed with it.
+type RepeatFailure struct {
+	Message string
+	Count   int
+}
+
+// Runs is the number of runs with an outcome.
+func (s *RepeatStats) Runs() int { return s.Passed + s.Failed + s.Skipped }
+
+// MinMedianMax returns the minimum, median, and maximum durations, or false if
+// there are none.
+func (s *RepeatStats) MinMedianMax() (min, median, max time.Duration, ok bool) {
+	n := len(s.Durations)
+	if n == 0 {
+		return 0, 0, 0, false
+	}
+	median = s.Durations[n/2]
+	if n%2 == 0 {
+		median = (s.Durations[n/2-1] + s.Durations[n/2]) / 2
+	}
+	return s.Durations[0], median, s.Durations[n-1], true
+}
+
+// NewRepeatStats groups summary entries and durations by feature summary name.
+// Results are sorted by name.
+func NewRepeatStats(summary Summary, durations map[string][]time.Duration) []*RepeatStats {
+	byName := map[string]*RepeatStats{}
+	failures := map[string]map[string]int{}
+	get := func(name string) *RepeatStats {
+		stats := byName[name]
+		if stats == nil {
+			stats = &RepeatStats{Name: name}
+			byName[name] = stats
+			failures[name] = map[string]int{}
+		}
+		return stats
+	}
+	for _, entry := range summary {
+		stats := get(entry.Name)
+		switch entry.Outcome {
+		case FeaturePassed:
+			stats.Passed++
+		case cmd.FeatureFailed:
+			stats.Failed++
+			failures[entry.Name][entry.Message]++
+		case cmd.FeatureSkipped:
+			stats.Skipped++
+		}
+	}
+	for name, featureDurations := range durations {
+		stats := get(name)
+		stats.Durations = append([]time.Duration(nil), featureDurations...)
+		sort.Slice(stats.Durations, func(i, j int) bool { return stats.Durations[i] < stats.Durations[j] })
+	}
+	ret := make([]*RepeatStats, 0, len(byName))
+	for name, stats := range byName {
+		for message, count := range failures[name] {
+			stats.Failures = append(stats.Failures, RepeatFailure{Message: message, Count: count})
+		}
+		sort.Slice(stats.Failures, func(i, j int) bool {
+			if stats.Failures[i].Count != stats.Failures[j].Count {
+				return stats.Failures[i].Count > stats.Failures[j].Count
+			}
+			return stats.Failures[i].Message < stats.Failures[j].Message
+		})
+		ret = append(ret, stats)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// reportRepeatStats prints pass/fail counts, distinct failure messages, and
+// duration spread of each repeated feature in the run. Durations are measured
+// on the server the same way as for baselines.
+func (r *Runner) reportRepeatStats(ctx context.Context, run *cmd.Run, summary Summary) (err error) {
+	ctx, span := tracer.Start(ctx, "reportRepeatStats")
+	defer func() { endSpan(span, err) }()
+
+	durations := map[string][]time.Duration{}
+	// Report counts even if durations cannot be measured
+	defer func() {
+		for _, stats := range NewRepeatStats(summary, durations) {
+			r.printRepeatStats(stats)
+		}
+	}()
+	cl, err := r.dialClient()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	for _, feature := range run.Features {
+		if feature.Config.NoWorkflow {
+			continue
+		}
+		if duration, ok, err := r.featureDuration(ctx, cl, feature); err != nil {
+			return err
+		} else if ok {
+			durations[feature.SummaryName()] = append(durations[feature.SummaryName()], duration)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) printRepeatStats(stats *RepeatStats) {
+	if min, median, max, ok := stats.MinMedianMax(); ok {
+		fmt.Printf("Feature repeat stats feature=%s runs=%d passed=%d failed=%d skipped=%d min=%v median=%v max=%v\n",
+			stats.Name, stats.Runs(), stats.Passed, stats.Failed, stats.Skipped, min, median, max)
+	} else {
+		fmt.Printf("Feature repeat stats feature=%s runs=%d passed=%d failed=%d skipped=%d\n",
+			stats.Name, stats.Runs(), stats.Passed, stats.Failed, stats.Skipped)
+	}
+	for _, failure := range stats.Failures {
+		fmt.Printf("Feature repeat failure feature=%s count=%d message=%q\n", stats.Name, failure.Count, failure.Message)
+	}
+}
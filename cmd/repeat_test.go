@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRepeatStatsGroupsFailures(t *testing.T) {
+	stats := NewRepeatStats(Summary{
+		{Name: "update/basic", Outcome: FeaturePassed},
+		{Name: "update/basic", Outcome: "FAILED", Message: "timed out"},
+		{Name: "timer/basic", Outcome: FeaturePassed},
+		{Name: "update/basic", Outcome: "FAILED", Message: "wrong result"},
+		{Name: "update/basic", Outcome: "FAILED", Message: "timed out"},
+		{Name: "update/basic", Outcome: "SKIPPED"},
+	}, map[string][]time.Duration{
+		"update/basic": {3 * time.Second, 1 * time.Second, 2 * time.Second, 4 * time.Second},
+	})
+	if len(stats) != 2 || stats[0].Name != "timer/basic" || stats[1].Name != "update/basic" {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+	if _, _, _, ok := stats[0].MinMedianMax(); ok {
+		t.Fatal("expected no durations for timer/basic")
+	}
+	update := stats[1]
+	if update.Runs() != 5 || update.Passed != 1 || update.Failed != 3 || update.Skipped != 1 {
+		t.Fatalf("unexpected counts %+v", update)
+	}
+	expectedFailures := []RepeatFailure{{Message: "timed out", Count: 2}, {Message: "wrong result", Count: 1}}
+	if !reflect.DeepEqual(update.Failures, expectedFailures) {
+		t.Fatalf("failures = %v", update.Failures)
+	}
+	min, median, max, ok := update.MinMedianMax()
+	if !ok || min != time.Second || median != 2500*time.Millisecond || max != 4*time.Second {
+		t.Fatalf("durations min=%v median=%v max=%v", min, median, max)
+	}
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/temporalio/features/harness/go/cmd"
+	"github.com/temporalio/features/harness/go/harness"
+	"github.com/temporalio/features/harness/go/history"
+	"github.com/urfave/cli/v2"
+)
+
+func replayCmd() *cli.Command {
+	var config ReplayConfig
+	return &cli.Command{
+		Name:  "replay",
+		Usage: "Replay stored history of a feature without a server",
+		Flags: config.flags(),
+		Action: func(ctx *cli.Context) error {
+			config.Files = ctx.StringSlice("file")
+			return NewReplayer(config).Replay(ctx.Context)
+		},
+	}
+}
+
+// ReplayConfig is configuration for NewReplayer.
+type ReplayConfig struct {
+	Lang    string
+	Feature string
+	// History files to replay, by name in the feature's history directory or
+	// by path. All of the language's stored history files if empty.
+	Files []string
+}
+
+func (r *ReplayConfig) flags() []cli.Flag {
+	return []cli.Flag{
+		langFlag(&r.Lang),
+		&cli.StringFlag{
+			Name:        "feature",
+			Usage:       "Feature directory relative to the features directory, e.g. activity/basic",
+			Required:    true,
+			Destination: &r.Feature,
+		},
+		&cli.StringSliceFlag{
+			Name:  "file",
+			Usage: "History file to replay, default is every stored history file of the language",
+		},
+	}
+}
+
+// Replayer replays stored histories of a feature.
+type Replayer struct {
+	config  ReplayConfig
+	rootDir string
+}
+
+// NewReplayer creates a new replayer.
+func NewReplayer(config ReplayConfig) *Replayer {
+	return &Replayer{config: config, rootDir: rootDir()}
+}
+
+// Replay replays every configured history file of the feature, printing any
+// replay failure.
+func (r *Replayer) Replay(ctx context.Context) error {
+	var err error
+	if r.config.Lang, err = normalizeLangName(r.config.Lang); err != nil {
+		return err
+	} else if r.config.Lang != "go" {
+		return fmt.Errorf("replay only supported for go")
+	}
+	featureDir := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(r.config.Feature), "features/"), "/")
+
+	// Find the feature and its config
+	var feature *harness.PreparedFeature
+	for _, maybeFeature := range harness.RegisteredFeatures() {
+		if maybeFeature.Dir == featureDir {
+			feature = maybeFeature
+			break
+		}
+	}
+	if feature == nil {
+		return fmt.Errorf("feature %v not found, did you add it to features.go?", featureDir)
+	}
+	var featureConfig cmd.RunFeatureConfig
+	if err := featureConfig.LoadFromDir(filepath.Join(r.rootDir, "features", featureDir)); err != nil {
+		return fmt.Errorf("failed reading config for %v: %w", featureDir, err)
+	}
+
+	runner := harness.NewReplayRunner(harness.RunnerConfig{
+		Log:            harness.NewCLILogger(),
+		HistoryCompare: featureConfig.HistoryCompare,
+	}, feature)
+	files, err := r.historyFiles(featureDir, runner)
+	if err != nil {
+		return err
+	} else if len(files) == 0 {
+		return fmt.Errorf("no %v history files to replay found for %v", r.config.Lang, featureDir)
+	}
+
+	var failed int
+	for _, file := range files {
+		hist, err := history.ReadFile(file.Path, file.Format)
+		if err == nil {
+			err = runner.ReplayHistories(ctx, hist)
+		}
+		relPath := file.Path
+		if rel, relErr := filepath.Rel(r.rootDir, file.Path); relErr == nil {
+			relPath = filepath.ToSlash(rel)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("Replay failed feature=%s file=%s\n%v\n", featureDir, relPath, err)
+		} else {
+			fmt.Printf("Replay passed feature=%s file=%s\n", featureDir, relPath)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v of %v history file(s) failed replay", failed, len(files))
+	}
+	return nil
+}
+
+// historyFiles returns the configured history files, or if none are
+// configured, every stored file the runner replays when checking history. That
+// leaves out files of later versions and files the feature's version policy
+// ignores.
+func (r *Replayer) historyFiles(featureDir string, runner *harness.Runner) ([]*history.StoredFile, error) {
+	storage := &history.Storage{Dir: filepath.Join(r.rootDir, "features", featureDir, "history"), Lang: r.config.Lang}
+	if len(r.config.Files) == 0 {
+		files, err := runner.StoredHistoryFiles()
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		return files, err
+	}
+	files := make([]*history.StoredFile, len(r.config.Files))
+	for i, file := range r.config.Files {
+		// Files not present as given are relative to the history directory
+		if _, err := os.Stat(file); err != nil {
+			file = filepath.Join(storage.Dir, file)
+		}
+		files[i] = &history.StoredFile{Path: file, Format: history.FileFormat(file)}
+		if files[i].Format == "" {
+			return nil, fmt.Errorf("unknown history format for %v", file)
+		}
+	}
+	return files, nil
+}
+
+// replayOnly replays the stored histories of the features that have any in the
+// language harness instead of running them. No server is started.
+func (r *Runner) replayOnly(ctx context.Context, features []*RunFeature) (err error) {
+	ctx, span := tracer.Start(ctx, "replayOnly")
+	defer func() { endSpan(span, err) }()
+
+	run := &cmd.Run{}
+	for _, feature := range features {
+		// Features opted in to cross-language replay replay every language
+		langs := []string{r.config.Lang}
+		if feature.Config.CrossLanguageReplay {
+			langs = history.Languages
+		}
+		var hasFiles bool
+		for _, lang := range langs {
+			storage := &history.Storage{Dir: filepath.Join(r.rootDir, "features", feature.Dir, "history"), Lang: lang}
+			files, err := storage.Files()
+			if err != nil {
+				return err
+			}
+			hasFiles = hasFiles || len(files) > 0
+		}
+		if !hasFiles {
+			r.log.Debug("Skipping feature without stored history", "Feature", feature.Dir)
+			continue
+		}
+		run.Features = append(run.Features, cmd.RunFeature{
+			Dir:       feature.Dir,
+			TaskQueue: r.taskQueueForFeature(feature.Dir, ""),
+			Config:    feature.Config,
+		})
+	}
+	if len(run.Features) == 0 {
+		return fmt.Errorf("no matched features have stored history")
+	}
+	fmt.Printf("Replaying stored history features=%s\n", strings.Join(featureSummaryNames(run.Features), ","))
+
+	l, err := net.Listen("tcp", summaryListenAddr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	summaryChan := make(chan Summary)
+	go r.summaryServer(l, summaryChan, nil, false)
+	config := r.config
+	config.SummaryURI = "tcp://" + l.Addr().String()
+	origConfig := r.config
+	r.config = config
+	defer func() { r.config = origConfig }()
+
+	if err := r.prepareProgram(ctx, config); err != nil {
+		return err
+	}
+	harnessErr := r.runHarness(ctx, config, run)
+	l.Close()
+	if summary, ok := <-summaryChan; ok {
+		r.logFeatureSummary("replay", summary)
+	}
+	return harnessErr
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"os"
 	"os/signal"
@@ -14,6 +15,8 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -24,12 +27,15 @@ import (
 	"github.com/temporalio/features/harness/go/history"
 	"github.com/temporalio/features/sdkbuild"
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	namespacepb "go.temporal.io/api/namespace/v1"
 	nexuspb "go.temporal.io/api/nexus/v1"
 	"go.temporal.io/api/operatorservice/v1"
 	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/testsuite"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -39,6 +45,12 @@ import (
 // nexusFeatureDirPrefix marks features that require a per-test Nexus endpoint.
 const nexusFeatureDirPrefix = "nexus/"
 
+const (
+	HistoryDiffText    = "text"
+	HistoryDiffJSON    = "json"
+	HistoryDiffUnified = "unified"
+)
+
 const (
 	summaryListenAddr               = "127.0.0.1:0"
 	FeaturePassed                   = "PASSED"
@@ -75,11 +87,28 @@ type RunConfig struct {
 	CACertPath                string
 	TLSServerName             string
 	GenerateHistory           bool
+	GenerateHistoryDryRun     bool
 	DisableHistoryCheck       bool
 	RetainTempDir             bool
 	SummaryURI                string
 	HTTPProxyURL              string
 	NamespaceCapabilitiesJSON string
+	BaselineFile              string
+	WriteBaseline             bool
+	BaselineThresholds        BaselineThresholds
+	Watch                     bool
+	Shuffle                   bool
+	Seed                      int64
+	FailFast                  bool
+	Count                     int
+	Concurrency               int
+	LeakCheck                 string
+	LeakCleanup               bool
+	ReplayOnly                bool
+	HistoryDiffFormat         string
+	HistoryDecodePayloads     bool
+	HistoryCodecEndpoint      string
+	HistoryFormat             string
 }
 
 // dockerRunFlags are a subset of flags that apply when running in a docker container
@@ -123,8 +152,8 @@ func (r *RunConfig) flags() []cli.Flag {
 		langFlag(&r.Lang),
 		&cli.StringFlag{
 			Name: "version",
-			Usage: "SDK language version to run. Most languages support versions as paths. " +
-				"Version cannot be present if prepared directory is.",
+			Usage: "SDK language version to run, or \"latest\" for the latest released version. Most languages " +
+				"support versions as paths. Version cannot be present if prepared directory is.",
 			Destination: &r.Version,
 		},
 		&cli.BoolFlag{
@@ -132,6 +161,17 @@ func (r *RunConfig) flags() []cli.Flag {
 			Usage:       "Generate the history of the features that are run (overwrites any existing history)",
 			Destination: &r.GenerateHistory,
 		},
+		&cli.BoolFlag{
+			Name:        "generate-history-dry-run",
+			Usage:       "With --generate-history, only list the history files that would be created or overwritten",
+			Destination: &r.GenerateHistoryDryRun,
+		},
+		&cli.StringFlag{
+			Name:        "history-format",
+			Usage:       "Format to write generated history in: \"json\", \"json.gz\", or \"binpb\"",
+			Value:       history.FormatJSON,
+			Destination: &r.HistoryFormat,
+		},
 		&cli.BoolFlag{
 			Name:        "no-history-check",
 			Usage:       "Do not verify history matches",
@@ -142,11 +182,111 @@ func (r *RunConfig) flags() []cli.Flag {
 			Usage:       "Do not delete the temp directory after the run",
 			Destination: &r.RetainTempDir,
 		},
+		&cli.StringFlag{
+			Name:        "history-diff-format",
+			Usage:       "How to show history mismatches: \"text\" event differences, \"json\" event differences, or \"unified\" JSON diff",
+			Value:       HistoryDiffText,
+			Destination: &r.HistoryDiffFormat,
+		},
+		&cli.BoolFlag{
+			Name:        "history-decode-payloads",
+			Usage:       "Show decoded payload values in history mismatches instead of encoded bytes",
+			Destination: &r.HistoryDecodePayloads,
+		},
+		&cli.StringFlag{
+			Name: "history-codec-endpoint",
+			Usage: "Remote codec endpoint used with --history-decode-payloads before the default data converter " +
+				"(ignored for Go features with their own data converter)",
+			Destination: &r.HistoryCodecEndpoint,
+		},
 		&cli.StringFlag{
 			Name:        "prepared-dir",
 			Usage:       "Relative directory already prepared. Cannot include version with this.",
 			Destination: &r.DirName,
 		},
+		&cli.StringFlag{
+			Name:        "trace-file",
+			Usage:       "File to write OpenTelemetry spans of the runner's own phases to, in OTLP-JSON form (optional)",
+			Destination: &r.TraceFile,
+		},
+		&cli.BoolFlag{
+			Name:        "shuffle",
+			Usage:       "Randomize the order of features within each batch",
+			Destination: &r.Shuffle,
+		},
+		&cli.Int64Flag{
+			Name:        "seed",
+			Usage:       "Seed for --shuffle to reproduce a previous order (default is random and printed)",
+			Destination: &r.Seed,
+		},
+		&cli.BoolFlag{
+			Name:        "fail-fast",
+			Usage:       "Stop the run at the first failed feature, still checking history of features already run",
+			Destination: &r.FailFast,
+		},
+		&cli.IntFlag{
+			Name:        "count",
+			Usage:       "Run each feature this many times, each on a fresh task queue, and report statistics",
+			Value:       1,
+			Destination: &r.Count,
+		},
+		&cli.IntFlag{
+			Name:        "concurrency",
+			Usage:       "Number of harness runs to split each batch's features across, running at the same time",
+			Value:       1,
+			Destination: &r.Concurrency,
+		},
+		&cli.StringFlag{
+			Name: "leak-check",
+			Usage: "After each feature, look for workflows still running and pollers still active on its " +
+				"task queue and either \"warn\", \"fail\", or \"off\"",
+			Value:       LeakCheckOff,
+			Destination: &r.LeakCheck,
+		},
+		&cli.BoolFlag{
+			Name:        "leak-cleanup",
+			Usage:       "Terminate workflows left running by a feature",
+			Destination: &r.LeakCleanup,
+		},
+		&cli.BoolFlag{
+			Name:        "replay-only",
+			Usage:       "Do not run features, only replay their stored histories without a server (Go only)",
+			Destination: &r.ReplayOnly,
+		},
+		&cli.BoolFlag{
+			Name: "watch",
+			Usage: "Keep the server and prepared program alive and re-run affected features when " +
+				"their directories or the harness sources change",
+			Destination: &r.Watch,
+		},
+		&cli.StringFlag{
+			Name:        "baseline",
+			Usage:       "File of per-feature durations to compare this run against (optional)",
+			Destination: &r.BaselineFile,
+		},
+		&cli.BoolFlag{
+			Name:        "write-baseline",
+			Usage:       "Record the durations of this run into the --baseline file",
+			Destination: &r.WriteBaseline,
+		},
+		&cli.Float64Flag{
+			Name:        "baseline-warn-ratio",
+			Usage:       "Warn when a feature takes at least this many times its baseline duration (0 disables)",
+			Value:       1.5,
+			Destination: &r.BaselineThresholds.WarnRatio,
+		},
+		&cli.Float64Flag{
+			Name:        "baseline-fail-ratio",
+			Usage:       "Fail when a feature takes at least this many times its baseline duration (0 disables)",
+			Value:       3,
+			Destination: &r.BaselineThresholds.FailRatio,
+		},
+		&cli.DurationFlag{
+			Name:        "baseline-min-slowdown",
+			Usage:       "Never flag a feature that is slower than its baseline by less than this",
+			Value:       250 * time.Millisecond,
+			Destination: &r.BaselineThresholds.MinSlowdown,
+		},
 	}, r.dockerRunFlags()...)
 }
 
@@ -158,6 +298,11 @@ type Runner struct {
 	rootDir    string
 	createTime time.Time
 	program    sdkbuild.Program
+	// Only set when shuffling features
+	shuffleRand *rand.Rand
+	// Durations of passed features by summary name, only collected when
+	// comparing against a baseline
+	featureDurations map[string]time.Duration
 }
 
 type runBatch struct {
@@ -173,10 +318,11 @@ type runBatch struct {
 func NewRunner(config RunConfig) *Runner {
 	return &Runner{
 		// TODO(cretz): Configurable logger
-		log:        harness.NewCLILogger(),
-		config:     config,
-		rootDir:    rootDir(),
-		createTime: time.Now(),
+		log:              harness.NewCLILogger(),
+		config:           config,
+		rootDir:          rootDir(),
+		createTime:       time.Now(),
+		featureDurations: map[string]time.Duration{},
 	}
 }
 
@@ -184,28 +330,38 @@ func (r *Runner) makeRunBatches(features []*RunFeature) []runBatch {
 	defaultBatch := runBatch{Run: &cmd.Run{}}
 	var batches []runBatch
 	for _, feature := range features {
+		cases := r.featureCases(feature.Dir)
 		if len(feature.Config.RunVariants) == 0 {
-			defaultBatch.Run.Features = append(defaultBatch.Run.Features, cmd.RunFeature{
-				Dir:       feature.Dir,
-				TaskQueue: r.taskQueueForFeature(feature.Dir, ""),
-				Config:    feature.Config,
-			})
+			for range max(r.config.Count, 1) {
+				for _, featureCase := range cases {
+					defaultBatch.Run.Features = append(defaultBatch.Run.Features, cmd.RunFeature{
+						Dir:       feature.Dir,
+						TaskQueue: r.taskQueueForFeature(feature.Dir, featureCase),
+						Config:    feature.Config,
+						CaseName:  featureCase,
+					})
+				}
+			}
 			if feature.Config.ExpectUnauthedProxyCount > 0 || feature.Config.ExpectAuthedProxyCount > 0 {
 				defaultBatch.ExpectsProxy = true
 			}
 			continue
 		}
 		for _, variant := range feature.Config.RunVariants {
-			runFeature := cmd.RunFeature{
-				Dir:         feature.Dir,
-				TaskQueue:   r.taskQueueForFeature(feature.Dir, variant.Name),
-				Config:      feature.Config,
-				VariantName: variant.Name,
+			var runFeatures []cmd.RunFeature
+			for range max(r.config.Count, 1) {
+				for _, featureCase := range cases {
+					runFeatures = append(runFeatures, cmd.RunFeature{
+						Dir:         feature.Dir,
+						TaskQueue:   r.taskQueueForFeature(feature.Dir, variant.Name, featureCase),
+						Config:      feature.Config,
+						VariantName: variant.Name,
+						CaseName:    featureCase,
+					})
+				}
 			}
 			batches = append(batches, runBatch{
-				Run: &cmd.Run{Features: []cmd.RunFeature{
-					runFeature,
-				}},
+				Run:              &cmd.Run{Features: runFeatures},
 				VariantName:      variant.Name,
 				DynamicConfig:    variant.DynamicConfig,
 				Capabilities:     variant.ExpectNamespaceCapabilities,
@@ -217,14 +373,58 @@ func (r *Runner) makeRunBatches(features []*RunFeature) []runBatch {
 	if len(defaultBatch.Run.Features) > 0 {
 		batches = append([]runBatch{defaultBatch}, batches...)
 	}
+	if r.shuffleRand != nil {
+		for _, batch := range batches {
+			r.shuffleRand.Shuffle(len(batch.Run.Features), func(i, j int) {
+				batch.Run.Features[i], batch.Run.Features[j] = batch.Run.Features[j], batch.Run.Features[i]
+			})
+		}
+	}
 	return batches
 }
 
-func (r *Runner) taskQueueForFeature(dir string, variant string) string {
-	if variant == "" {
-		return fmt.Sprintf("features-%v-%v", dir, uuid.NewString())
+// taskQueueForFeature returns a new task queue for the feature, qualified with
+// each non-empty variant or case name given.
+func (r *Runner) taskQueueForFeature(dir string, qualifiers ...string) string {
+	taskQueue := "features-" + dir
+	for _, qualifier := range qualifiers {
+		if qualifier != "" {
+			taskQueue += "-" + qualifier
+		}
+	}
+	return taskQueue + "-" + uuid.NewString()
+}
+
+// featureCases returns the names of the cases of the table-driven Go feature,
+// or a single empty name if the feature has no cases or the language is not
+// Go.
+func (r *Runner) featureCases(dir string) []string {
+	if r.config.Lang == "go" {
+		for _, goFeature := range harness.RegisteredFeatures() {
+			if goFeature.Dir == dir && len(goFeature.Cases) > 0 {
+				cases := make([]string, len(goFeature.Cases))
+				for i, featureCase := range goFeature.Cases {
+					cases[i] = featureCase.Name
+				}
+				return cases
+			}
+		}
+	}
+	return []string{""}
+}
+
+// featureTaskQueueSuffixes returns the task queue suffixes of the named workers
+// of the Go feature, whose task queues are used alongside the feature's own. It
+// returns none if the language is not Go.
+func (r *Runner) featureTaskQueueSuffixes(dir string) []string {
+	if r.config.Lang == "go" {
+		for _, goFeature := range harness.RegisteredFeatures() {
+			if goFeature.Dir == dir {
+				return goFeature.TaskQueueSuffixes()
+			}
+		}
 	}
-	return fmt.Sprintf("features-%v-%v-%v", dir, variant, uuid.NewString())
+	return nil
 }
 
 type dynamicConfigValue struct {
@@ -327,8 +527,21 @@ func (r *Runner) assertNamespaceCapabilities(ctx context.Context, config RunConf
 
 // Run runs all matching features for the given patterns (or all if no patterns
 // given).
-func (r *Runner) Run(ctx context.Context, patterns []string) error {
-	var err error
+func (r *Runner) Run(ctx context.Context, patterns []string) (err error) {
+	if r.config.TraceFile != "" {
+		stopTracing, err := startTracing(ctx, r.config.TraceFile)
+		if err != nil {
+			return err
+		}
+		defer stopTracing()
+	}
+	ctx, span := tracer.Start(ctx, "Run", trace.WithAttributes(
+		attribute.String("lang", r.config.Lang),
+		attribute.String("version", r.config.Version),
+		attribute.StringSlice("patterns", patterns),
+	))
+	defer func() { endSpan(span, err) }()
+
 	if r.config.Lang, err = normalizeLangName(r.config.Lang); err != nil {
 		return err
 	}
@@ -336,6 +549,50 @@ func (r *Runner) Run(ctx context.Context, patterns []string) error {
 	// Cannot generate history if a version isn't provided explicitly
 	if r.config.GenerateHistory && r.config.Version == "" {
 		return fmt.Errorf("must have explicit version to generate history")
+	} else if r.config.GenerateHistoryDryRun && !r.config.GenerateHistory {
+		return fmt.Errorf("dry run only supported when generating history")
+	}
+	if r.config.Version == LatestVersion {
+		if r.config.Version, err = r.resolveLatestVersion(); err != nil {
+			return err
+		}
+	}
+
+	if r.config.Watch && r.config.GenerateHistory {
+		return fmt.Errorf("cannot generate history in watch mode")
+	}
+	if r.config.ReplayOnly {
+		if r.config.Lang != "go" {
+			return fmt.Errorf("replay only mode only supported for go")
+		} else if r.config.GenerateHistory || r.config.Watch {
+			return fmt.Errorf("cannot generate history or watch in replay only mode")
+		}
+	}
+	if r.config.Seed != 0 && !r.config.Shuffle {
+		return fmt.Errorf("seed can only be provided with shuffle")
+	}
+	switch r.config.HistoryDiffFormat {
+	case "", HistoryDiffText, HistoryDiffJSON, HistoryDiffUnified:
+	default:
+		return fmt.Errorf("history diff format must be %q, %q, or %q", HistoryDiffText, HistoryDiffJSON, HistoryDiffUnified)
+	}
+	if err := history.ValidateFormat(r.config.HistoryFormat); err != nil {
+		return err
+	}
+	switch r.config.LeakCheck {
+	case "":
+		r.config.LeakCheck = LeakCheckOff
+	case LeakCheckOff, LeakCheckWarn, LeakCheckFail:
+	default:
+		return fmt.Errorf("leak check must be %q, %q, or %q", LeakCheckOff, LeakCheckWarn, LeakCheckFail)
+	}
+	if r.config.Count < 0 {
+		return fmt.Errorf("count cannot be negative")
+	} else if r.config.Count > 1 && r.config.GenerateHistory {
+		return fmt.Errorf("cannot generate history of repeated runs")
+	}
+	if r.config.WriteBaseline && r.config.BaselineFile == "" {
+		return fmt.Errorf("must have baseline file to write baseline")
 	}
 
 	// If prepared dir given, validate
@@ -356,13 +613,14 @@ func (r *Runner) Run(ctx context.Context, patterns []string) error {
 		return err
 	} else if len(features) == 0 {
 		return fmt.Errorf("no features matched")
-	} else if len(features) > 1 && r.config.GenerateHistory {
-		return fmt.Errorf("can only specify a single feature when generating history")
 	}
 	if r.config.GenerateHistory {
 		for _, feature := range features {
-			if len(feature.Config.RunVariants) > 0 {
-				return fmt.Errorf("cannot generate history for feature %q because it defines runVariants", feature.Dir)
+			// Each variant's history is stored in its own file
+			for _, variant := range feature.Config.RunVariants {
+				if err := history.ValidateVariant(variant.Name); err != nil {
+					return fmt.Errorf("cannot generate history for feature %q: %w", feature.Dir, err)
+				}
 			}
 		}
 	}
@@ -370,19 +628,39 @@ func (r *Runner) Run(ctx context.Context, patterns []string) error {
 	if len(features) == 0 {
 		return fmt.Errorf("no features matched")
 	}
+	if r.config.GenerateHistoryDryRun {
+		return r.printHistoryToGenerate(features)
+	}
 
 	// Ensure any created temp dir is cleaned on ctrl-c or normal exit
 	if r.config.DirName == "" && !r.config.RetainTempDir {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-		go func() {
-			<-c
-			r.destroyTempDir()
-			os.Exit(1)
-		}()
+		// Watch mode handles ctrl-c itself by canceling its context
+		if !r.config.Watch {
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-c
+				r.destroyTempDir()
+				os.Exit(1)
+			}()
+		}
 		defer r.destroyTempDir()
 	}
 
+	if r.config.Shuffle {
+		if r.config.Seed == 0 {
+			r.config.Seed = time.Now().UnixNano()
+		}
+		r.shuffleRand = rand.New(rand.NewSource(r.config.Seed))
+		fmt.Printf("Shuffling feature order, rerun with --shuffle --seed %d to reproduce\n", r.config.Seed)
+	}
+
+	if r.config.ReplayOnly {
+		return r.replayOnly(ctx, features)
+	} else if r.config.Watch {
+		return r.watch(ctx, patterns, features)
+	}
+
 	batches := r.makeRunBatches(features)
 	for i, batch := range batches {
 		if i > 0 {
@@ -392,6 +670,9 @@ func (r *Runner) Run(ctx context.Context, patterns []string) error {
 			return err
 		}
 	}
+	if r.config.BaselineFile != "" {
+		return r.checkBaseline()
+	}
 	return nil
 }
 
@@ -413,7 +694,13 @@ func (r *Runner) filterFeaturesForExternalServer(features []*RunFeature, pattern
 	return filtered
 }
 
-func (r *Runner) runBatch(ctx context.Context, batch runBatch) error {
+func (r *Runner) runBatch(ctx context.Context, batch runBatch) (err error) {
+	ctx, span := tracer.Start(ctx, "runBatch", trace.WithAttributes(
+		attribute.String("variant", batch.VariantName),
+		attribute.StringSlice("features", featureSummaryNames(batch.Run.Features)),
+	))
+	defer func() { endSpan(span, err) }()
+
 	config := r.config
 	config.NamespaceCapabilitiesJSON = batch.CapabilitiesJSON
 	if config.Namespace == "" {
@@ -432,11 +719,13 @@ func (r *Runner) runBatch(ctx context.Context, batch runBatch) error {
 		if err != nil {
 			return err
 		}
-		server, err := testsuite.StartDevServer(ctx, testsuite.DevServerOptions{
+		serverCtx, serverSpan := tracer.Start(ctx, "StartDevServer")
+		server, err := testsuite.StartDevServer(serverCtx, testsuite.DevServerOptions{
 			LogLevel:      "error",
 			ClientOptions: &client.Options{Namespace: config.Namespace},
 			ExtraArgs:     dynamicConfigArgs,
 		})
+		endSpan(serverSpan, err)
 		if err != nil {
 			return fmt.Errorf("failed starting devserver: %w", err)
 		}
@@ -447,8 +736,10 @@ func (r *Runner) runBatch(ctx context.Context, batch runBatch) error {
 		if batch.VariantName != "" {
 			return fmt.Errorf("feature run variant %q requires the embedded dev server, but --server was provided", label)
 		}
-		err := harness.WaitNamespaceAvailable(ctx, r.log,
+		waitCtx, waitSpan := tracer.Start(ctx, "WaitNamespaceAvailable")
+		err := harness.WaitNamespaceAvailable(waitCtx, r.log,
 			config.Server, config.Namespace, config.ClientCertPath, config.ClientKeyPath, config.CACertPath, config.TLSServerName)
+		endSpan(waitSpan, err)
 		if err != nil {
 			return err
 		}
@@ -487,8 +778,6 @@ func (r *Runner) runBatch(ctx context.Context, batch runBatch) error {
 		return err
 	}
 	defer l.Close()
-	summaryChan := make(chan Summary)
-	go r.summaryServer(l, summaryChan)
 	config.SummaryURI = "tcp://" + l.Addr().String()
 
 	r.log.Info("Running feature batch", "Variant", label, "Features", featureSummaryNames(batch.Run.Features))
@@ -504,86 +793,31 @@ func (r *Runner) runBatch(ctx context.Context, batch runBatch) error {
 		r.config = origConfig
 	}()
 
-	err = nil
-	switch config.Lang {
-	case "go":
-		// If there's a version or prepared dir we run external, otherwise we run local
-		if config.Version != "" || config.DirName != "" {
-			if config.DirName != "" {
-				r.program, err = sdkbuild.GoProgramFromDir(filepath.Join(r.rootDir, config.DirName))
-			}
-			if err == nil {
-				err = r.RunGoExternal(ctx, batch.Run)
-			}
-		} else {
-			err = cmd.NewRunner(cmd.RunConfig{
-				Server:         config.Server,
-				Namespace:      config.Namespace,
-				ClientCertPath: config.ClientCertPath,
-				ClientKeyPath:  config.ClientKeyPath,
-				CACertPath:     config.CACertPath,
-				TLSServerName:  config.TLSServerName,
-				SummaryURI:     config.SummaryURI,
-				HTTPProxyURL:   config.HTTPProxyURL,
-			}).Run(ctx, batch.Run)
-		}
-	case "java":
-		if config.DirName != "" {
-			r.program, err = sdkbuild.JavaProgramFromDir(filepath.Join(r.rootDir, config.DirName))
-		}
-		if err == nil {
-			err = r.RunJavaExternal(ctx, batch.Run)
-		}
-	case "ts":
-		if config.DirName != "" {
-			r.program, err = sdkbuild.TypeScriptProgramFromDir(filepath.Join(r.rootDir, config.DirName))
-		}
-		if err == nil {
-			err = r.RunTypeScriptExternal(ctx, batch.Run)
-		}
-	case "php":
-		if config.DirName != "" {
-			r.program, err = sdkbuild.PhpProgramFromDir(
-				filepath.Join(r.rootDir, config.DirName),
-				r.rootDir,
-			)
-		}
-		if err == nil {
-			err = r.RunPhpExternal(ctx, batch.Run)
-		}
-	case "py":
-		if config.DirName != "" {
-			r.program, err = sdkbuild.PythonProgramFromDir(filepath.Join(r.rootDir, config.DirName))
-		}
-		if err == nil {
-			err = r.RunPythonExternal(ctx, batch.Run)
-		}
-	case "cs":
-		if config.DirName != "" {
-			r.program, err = sdkbuild.DotNetProgramFromDir(filepath.Join(r.rootDir, config.DirName))
-		}
-		if err == nil {
-			err = r.RunDotNetExternal(ctx, batch.Run)
-		}
-	case "rb":
-		if config.DirName != "" {
-			r.program, err = sdkbuild.RubyProgramFromDir(
-				filepath.Join(r.rootDir, config.DirName),
-				filepath.Join(r.rootDir, "harness", "ruby"),
-			)
-		}
-		if err == nil {
-			err = r.RunRubyExternal(ctx, batch.Run)
-		}
-	default:
-		err = fmt.Errorf("unrecognized language")
+	if err := r.prepareProgram(ctx, config); err != nil {
+		return err
 	}
+	leaks, err := r.startLeakCheck(ctx, batch.Run)
 	if err != nil {
 		return err
 	}
-	l.Close()
-	summary, ok := <-summaryChan
-	if !ok {
+	var hooks batchHooks
+	var leakErr error
+	if leaks != nil {
+		hooks.onSummaryEntry = append(hooks.onSummaryEntry, leaks.featureFinished)
+		// All leak checks are done before the next batch however this one ends,
+		// and leaked workflows do not hold up fetching history
+		hooks.onHarnessesExit = append(hooks.onHarnessesExit, func() { leakErr = leaks.finish(time.Now()) })
+	}
+	// Only the Go harness can stop at the first failed feature itself
+	stopAtFailure := config.FailFast && config.Lang != "go"
+	summary, received, harnessErr := r.runHarnessesWithHooks(ctx, l, stopAtFailure, hooks,
+		func(ctx context.Context) error { return r.runHarnesses(ctx, config, batch.Run) })
+	// With fail-fast, a failed harness still has its summary reported and the
+	// history of features that passed checked before the run stops. Repeated
+	// features always report their statistics, failures included.
+	if harnessErr != nil && ((!config.FailFast && config.Count <= 1) || !received) {
+		return errors.Join(harnessErr, leakErr)
+	} else if !received {
 		r.log.Debug("did not receive a test run summary - adopting legacy behavior of assuming no tests were skipped")
 		for _, feature := range batch.Run.Features {
 			summary = append(summary, SummaryEntry{Name: feature.SummaryName(), Outcome: FeaturePassed})
@@ -592,43 +826,258 @@ func (r *Runner) runBatch(ctx context.Context, batch runBatch) error {
 		summary = rewriteVariantSummary(summary, batch.Run.Features)
 	}
 	r.logFeatureSummary(label, summary)
+	return errors.Join(r.checkBatchSummary(ctx, batch, summary, harnessErr, proxyServer), leakErr)
+}
+
+// batchHooks are how the concerns of a batch that follow its features as they
+// finish plug into running its harnesses.
+type batchHooks struct {
+	// Called with every summary entry as soon as it is read
+	onSummaryEntry []func(SummaryEntry)
+	// Called in order once every harness has exited, however it exited, before
+	// the summary is collected
+	onHarnessesExit []func()
+}
+
+// failFastStopper stops harnesses at the first failed feature by canceling
+// their context.
+type failFastStopper struct {
+	log     log.Logger
+	cancel  context.CancelFunc
+	stopped atomic.Bool
+}
+
+func (f *failFastStopper) onSummaryEntry(entry SummaryEntry) {
+	if entry.Outcome == cmd.FeatureFailed && !f.stopped.Swap(true) {
+		f.log.Warn("Stopping harness after first failed feature")
+		f.cancel()
+	}
+}
+
+// runHarnessesWithHooks runs the harnesses, collecting their summary from the
+// listener and passing each entry to the hooks as it is read. If stopAtFailure
+// is set, the harnesses are stopped at the first failed feature, which then
+// only fails through the summary. It returns the summary, whether any was
+// received, and the harness error.
+func (r *Runner) runHarnessesWithHooks(
+	ctx context.Context,
+	l net.Listener,
+	stopAtFailure bool,
+	hooks batchHooks,
+	runHarnesses func(context.Context) error,
+) (Summary, bool, error) {
+	harnessCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stopper *failFastStopper
+	if stopAtFailure {
+		stopper = &failFastStopper{log: r.log, cancel: cancel}
+		hooks.onSummaryEntry = append(hooks.onSummaryEntry, stopper.onSummaryEntry)
+	}
+	summaryChan := make(chan Summary, 1)
+	go r.summaryServer(l, summaryChan, hooks.onSummaryEntry, stopAtFailure)
+
+	harnessCtx, harnessSpan := tracer.Start(harnessCtx, "RunHarness", trace.WithAttributes(
+		attribute.String("lang", r.config.Lang),
+	))
+	harnessErr := runHarnesses(harnessCtx)
+	if stopper != nil && stopper.stopped.Load() && ctx.Err() == nil {
+		harnessErr = nil
+	}
+	endSpan(harnessSpan, harnessErr)
+	for _, onExit := range hooks.onHarnessesExit {
+		onExit()
+	}
+	l.Close()
+	summary, received := <-summaryChan
+	return summary, received, harnessErr
+}
 
-	// For features that expected proxy connections, count how many expected
-	// ignoring skips and compare count with actual. If any failed we don't need
-	// even do the comparison.
+// checkBatchSummary does the checks of a batch that need its whole summary.
+// With fail-fast, the history of features that passed is still checked when
+// one failed, but then the batch fails without further checks.
+func (r *Runner) checkBatchSummary(
+	ctx context.Context,
+	batch runBatch,
+	summary Summary,
+	harnessErr error,
+	proxyServer *harness.HTTPConnectProxyServer,
+) error {
+	if r.config.Count > 1 {
+		if err := r.reportRepeatStats(ctx, batch.Run, summary); err != nil {
+			r.log.Error("Failed reporting repeat statistics", "error", err)
+		}
+	}
+	if r.config.FailFast && (harnessErr != nil || summary.anyFailed()) {
+		if err := r.handleHistory(ctx, batch.Run, summary); err != nil {
+			r.log.Error("History check of features run before failure failed", "error", err)
+		}
+		if harnessErr != nil {
+			return harnessErr
+		}
+		return fmt.Errorf("stopping at first failed feature")
+	} else if harnessErr != nil {
+		return harnessErr
+	}
 	if proxyServer != nil {
-		var anyFailed bool
-		var expectUnauthedProxyCount, expectAuthedProxyCount int
-		for _, summ := range summary {
-			if summ.Outcome == "FAILED" {
-				anyFailed = true
-				break
-			} else if summ.Outcome == "PASSED" {
-				for _, feature := range batch.Run.Features {
-					if feature.SummaryName() == summ.Name {
-						expectUnauthedProxyCount += feature.Config.ExpectUnauthedProxyCount
-						expectAuthedProxyCount += feature.Config.ExpectAuthedProxyCount
-						break
-					}
+		if err := r.checkProxyConnections(proxyServer, batch.Run, summary); err != nil {
+			return err
+		}
+	}
+	if r.config.BaselineFile != "" {
+		if err := r.collectFeatureDurations(ctx, batch.Run, summary); err != nil {
+			return err
+		}
+	}
+	return r.handleHistory(ctx, batch.Run, summary)
+}
+
+// checkProxyConnections counts how many proxy connections the passed features
+// expected and compares that with the actual count. If any feature failed, the
+// counts are not compared.
+func (r *Runner) checkProxyConnections(
+	proxyServer *harness.HTTPConnectProxyServer,
+	run *cmd.Run,
+	summary Summary,
+) error {
+	var expectUnauthedProxyCount, expectAuthedProxyCount int
+	for _, summ := range summary {
+		if summ.Outcome == "FAILED" {
+			return nil
+		} else if summ.Outcome == "PASSED" {
+			for _, feature := range run.Features {
+				if feature.SummaryName() == summ.Name {
+					expectUnauthedProxyCount += feature.Config.ExpectUnauthedProxyCount
+					expectAuthedProxyCount += feature.Config.ExpectAuthedProxyCount
+					break
 				}
 			}
 		}
-		if !anyFailed {
-			if proxyServer.UnauthedConnectionsTunneled.Load() != uint32(expectUnauthedProxyCount) {
-				return fmt.Errorf("expected %v unauthed HTTP proxy connections, got %v",
-					expectUnauthedProxyCount, proxyServer.UnauthedConnectionsTunneled.Load())
-			} else if proxyServer.AuthedConnectionsTunneled.Load() != uint32(expectAuthedProxyCount) {
-				return fmt.Errorf("expected %v authed HTTP proxy connections, got %v",
-					expectAuthedProxyCount, proxyServer.AuthedConnectionsTunneled.Load())
-			} else {
-				r.log.Debug("Matched expected HTTP proxy connections",
-					"expectUnauthed", expectUnauthedProxyCount, "actualUnauthed", proxyServer.UnauthedConnectionsTunneled.Load(),
-					"expectAuthed", expectAuthedProxyCount, "actualAuthed", proxyServer.AuthedConnectionsTunneled.Load())
-			}
+	}
+	if proxyServer.UnauthedConnectionsTunneled.Load() != uint32(expectUnauthedProxyCount) {
+		return fmt.Errorf("expected %v unauthed HTTP proxy connections, got %v",
+			expectUnauthedProxyCount, proxyServer.UnauthedConnectionsTunneled.Load())
+	} else if proxyServer.AuthedConnectionsTunneled.Load() != uint32(expectAuthedProxyCount) {
+		return fmt.Errorf("expected %v authed HTTP proxy connections, got %v",
+			expectAuthedProxyCount, proxyServer.AuthedConnectionsTunneled.Load())
+	}
+	r.log.Debug("Matched expected HTTP proxy connections",
+		"expectUnauthed", expectUnauthedProxyCount, "actualUnauthed", proxyServer.UnauthedConnectionsTunneled.Load(),
+		"expectAuthed", expectAuthedProxyCount, "actualAuthed", proxyServer.AuthedConnectionsTunneled.Load())
+	return nil
+}
+
+// runHarnesses runs the harness for the given run, splitting its features
+// round-robin across up to config.Concurrency harness runs at the same time.
+// Repetitions of a feature are adjacent, so they are spread across harnesses.
+func (r *Runner) runHarnesses(ctx context.Context, config RunConfig, run *cmd.Run) error {
+	if config.Concurrency <= 1 || len(run.Features) <= 1 {
+		return r.runHarness(ctx, config, run)
+	}
+	runs := make([]*cmd.Run, min(config.Concurrency, len(run.Features)))
+	for i := range runs {
+		runs[i] = &cmd.Run{}
+	}
+	for i, feature := range run.Features {
+		runs[i%len(runs)].Features = append(runs[i%len(runs)].Features, feature)
+	}
+	errs := make([]error, len(runs))
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.runHarness(ctx, config, run)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// prepareProgram loads the program from the prepared dir, or builds it if not
+// already built, for languages that run out of process. It must be called
+// before runHarness so that concurrent harness runs share one program.
+func (r *Runner) prepareProgram(ctx context.Context, config RunConfig) (err error) {
+	if config.DirName != "" {
+		dir := filepath.Join(r.rootDir, config.DirName)
+		switch config.Lang {
+		case "go":
+			r.program, err = sdkbuild.GoProgramFromDir(dir)
+		case "java":
+			r.program, err = sdkbuild.JavaProgramFromDir(dir)
+		case "ts":
+			r.program, err = sdkbuild.TypeScriptProgramFromDir(dir)
+		case "php":
+			r.program, err = sdkbuild.PhpProgramFromDir(dir, r.rootDir)
+		case "py":
+			r.program, err = sdkbuild.PythonProgramFromDir(dir)
+		case "cs":
+			r.program, err = sdkbuild.DotNetProgramFromDir(dir)
+		case "rb":
+			r.program, err = sdkbuild.RubyProgramFromDir(dir, filepath.Join(r.rootDir, "harness", "ruby"))
 		}
+		return err
+	}
+	// Go without a version runs in-process and needs no program
+	if r.program != nil || (config.Lang == "go" && config.Version == "") {
+		return nil
+	}
+	preparer := NewPreparer(config.PrepareConfig)
+	switch config.Lang {
+	case "go":
+		r.program, err = preparer.BuildGoProgram(ctx)
+	case "java":
+		r.program, err = preparer.BuildJavaProgram(ctx, false)
+	case "ts":
+		r.program, err = preparer.BuildTypeScriptProgram(ctx)
+	case "php":
+		r.program, err = preparer.BuildPhpProgram(ctx)
+	case "py":
+		r.program, err = preparer.BuildPythonProgram(ctx)
+	case "cs":
+		r.program, err = preparer.BuildDotNetProgram(ctx)
+	case "rb":
+		r.program, err = preparer.BuildRubyProgram(ctx)
 	}
+	return err
+}
 
-	return r.handleHistory(ctx, batch.Run, summary)
+// runHarness runs the language-specific harness for the given run, either
+// in-process or as a subprocess using the program from prepareProgram.
+func (r *Runner) runHarness(ctx context.Context, config RunConfig, run *cmd.Run) error {
+	switch config.Lang {
+	case "go":
+		// If there's an already-built program we run external, otherwise we run
+		// local
+		if r.program != nil {
+			return r.RunGoExternal(ctx, run)
+		}
+		return cmd.NewRunner(cmd.RunConfig{
+			Server:         config.Server,
+			Namespace:      config.Namespace,
+			ClientCertPath: config.ClientCertPath,
+			ClientKeyPath:  config.ClientKeyPath,
+			CACertPath:     config.CACertPath,
+			TLSServerName:  config.TLSServerName,
+			SummaryURI:     config.SummaryURI,
+			HTTPProxyURL:   config.HTTPProxyURL,
+			FailFast:       config.FailFast,
+			ReplayOnly:     config.ReplayOnly,
+		}).Run(ctx, run)
+	case "java":
+		return r.RunJavaExternal(ctx, run)
+	case "ts":
+		return r.RunTypeScriptExternal(ctx, run)
+	case "php":
+		return r.RunPhpExternal(ctx, run)
+	case "py":
+		return r.RunPythonExternal(ctx, run)
+	case "cs":
+		return r.RunDotNetExternal(ctx, run)
+	case "rb":
+		return r.RunRubyExternal(ctx, run)
+	default:
+		return fmt.Errorf("unrecognized language")
+	}
 }
 
 func featureSummaryNames(features []cmd.RunFeature) []string {
@@ -668,25 +1117,38 @@ func formatMap(values map[string]any) string {
 func rewriteVariantSummary(summary Summary, features []cmd.RunFeature) Summary {
 	for i, entry := range summary {
 		for _, feature := range features {
-			if entry.Name == feature.Dir {
+			// The harness does not know the variant, only the case
+			harnessFeature := cmd.RunFeature{Dir: feature.Dir, CaseName: feature.CaseName}
+			if entry.Name == harnessFeature.SummaryName() {
 				summary[i].Name = feature.SummaryName()
 				break
+			} else if entry.Name == harnessFeature.CrossLanguageReplaySummaryName() {
+				summary[i].Name = feature.CrossLanguageReplaySummaryName()
+				break
 			}
 		}
 	}
 	return summary
 }
 
-func (r *Runner) handleHistory(ctx context.Context, run *cmd.Run, summary Summary) error {
+func (r *Runner) handleHistory(ctx context.Context, run *cmd.Run, summary Summary) (err error) {
+	ctx, span := tracer.Start(ctx, "handleHistory")
+	defer func() { endSpan(span, err) }()
+
 	// Handle each
 	var cl client.Client
 	var failureCount int
+	checked := map[string]bool{}
 	for _, feature := range run.Features {
-		// We ignore history if there are no workflows
-		if feature.Config.NoWorkflow {
+		// We ignore history if there are no workflows, and only check the first
+		// of repeated runs
+		if feature.Config.NoWorkflow || checked[feature.SummaryName()] {
 			continue
 		}
-		entry, ok := summary.Find(feature.SummaryName())
+		checked[feature.SummaryName()] = true
+		// Which repetition failed or was skipped is not known, so a repeated
+		// feature is only checked if all of its repetitions passed
+		entry, ok := summary.FindWorst(feature.SummaryName())
 		if !ok {
 			r.log.Info("skipping history check because feature not listed in execution summary", "feature", feature.SummaryName())
 			continue
@@ -695,26 +1157,15 @@ func (r *Runner) handleHistory(ctx context.Context, run *cmd.Run, summary Summar
 			r.log.Info("skipping history check because feature was skipped", "feature", feature.Dir, "reason", entry.Message)
 			continue
 		}
+		if entry.Outcome == "FAILED" {
+			r.log.Info("skipping history check because feature failed", "feature", feature.SummaryName())
+			continue
+		}
 
 		// Dial client if not already done
 		if cl == nil {
-			opts := client.Options{
-				HostPort:  r.config.Server,
-				Namespace: r.config.Namespace,
-				Logger:    r.log,
-			}
-			tlsCfg, err := harness.LoadTLSConfig(
-				r.config.ClientCertPath,
-				r.config.ClientKeyPath,
-				r.config.CACertPath,
-				r.config.TLSServerName,
-			)
-			if err != nil {
-				return fmt.Errorf("failed to load TLS config: %w", err)
-			}
-			opts.ConnectionOptions.TLS = tlsCfg
-			if cl, err = client.Dial(opts); err != nil {
-				return fmt.Errorf("failed creating client: %w", err)
+			if cl, err = r.dialClient(); err != nil {
+				return err
 			}
 			defer cl.Close()
 		}
@@ -731,26 +1182,165 @@ func (r *Runner) handleHistory(ctx context.Context, run *cmd.Run, summary Summar
 	return nil
 }
 
-func (r *Runner) handleSingleHistory(ctx context.Context, client client.Client, feature cmd.RunFeature) error {
+// LatestVersion is the version that resolves to the latest released SDK
+// version of the language.
+const LatestVersion = "latest"
+
+// resolveLatestVersion returns the latest released SDK version of the language
+// in the form history files are stored in, which is prefixed with "v" only for
+// Go.
+func (r *Runner) resolveLatestVersion() (string, error) {
+	version, err := latestSdkVersion(r.config.Lang)
+	if err != nil {
+		return "", fmt.Errorf("failed resolving latest version: %w", err)
+	}
+	version = strings.TrimPrefix(version, "v")
+	if r.config.Lang == "go" {
+		version = "v" + version
+	}
+	fmt.Printf("Resolved latest version lang=%s version=%s\n", r.config.Lang, version)
+	return version, nil
+}
+
+// generatedHistory is a history file that generating history would write.
+type generatedHistory struct {
+	Feature string
+	File    string
+	// Existing file of the same version that would be overwritten, which is in a
+	// different format than File if the format is changing. Empty if none.
+	Existing string
+}
+
+// historyToGenerate returns the history files that generating history for the
+// features would write, one per feature and run variant.
+func (r *Runner) historyToGenerate(features []*RunFeature) ([]generatedHistory, error) {
+	var toGenerate []generatedHistory
+	for _, feature := range features {
+		// Features without workflows have no history
+		if feature.Config.NoWorkflow {
+			continue
+		}
+		variants := []string{""}
+		if len(feature.Config.RunVariants) > 0 {
+			variants = variants[:0]
+			for _, variant := range feature.Config.RunVariants {
+				variants = append(variants, variant.Name)
+			}
+		}
+		for _, variant := range variants {
+			for _, featureCase := range r.featureCases(feature.Dir) {
+				runFeature := cmd.RunFeature{Dir: feature.Dir, VariantName: variant, CaseName: featureCase}
+				storage := &history.Storage{
+					Dir:     filepath.Join(r.rootDir, "features", feature.Dir, "history"),
+					Lang:    r.config.Lang,
+					Format:  r.config.HistoryFormat,
+					Variant: runFeature.HistoryVariant(),
+				}
+				files, err := storage.Files()
+				if err != nil {
+					return nil, err
+				}
+				gen := generatedHistory{Feature: runFeature.SummaryName()}
+				gen.File = storage.File(r.config.Version)
+				for _, file := range files {
+					if file.Variant == storage.Variant && file.Version == r.config.Version {
+						gen.Existing = file.Path
+					}
+				}
+				toGenerate = append(toGenerate, gen)
+			}
+		}
+	}
+	return toGenerate, nil
+}
+
+// printHistoryToGenerate prints the history files that generating history for
+// the features would create or overwrite, without running anything.
+func (r *Runner) printHistoryToGenerate(features []*RunFeature) error {
+	toGenerate, err := r.historyToGenerate(features)
+	if err != nil {
+		return err
+	}
+	for _, gen := range toGenerate {
+		file := r.relPath(gen.File)
+		switch gen.Existing {
+		case "":
+			fmt.Printf("Would create history feature=%s file=%s\n", gen.Feature, file)
+		case gen.File:
+			fmt.Printf("Would overwrite history feature=%s file=%s\n", gen.Feature, file)
+		default:
+			fmt.Printf("Would overwrite history feature=%s file=%s replacing=%s\n", gen.Feature, file, r.relPath(gen.Existing))
+		}
+	}
+	return nil
+}
+
+// relPath returns the /-slashed path relative to the root for display.
+func (r *Runner) relPath(path string) string {
+	if rel, err := filepath.Rel(r.rootDir, path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// dialClient creates a client for the currently configured server and
+// namespace.
+func (r *Runner) dialClient() (client.Client, error) {
+	opts := client.Options{
+		HostPort:  r.config.Server,
+		Namespace: r.config.Namespace,
+		Logger:    r.log,
+	}
+	tlsCfg, err := harness.LoadTLSConfig(
+		r.config.ClientCertPath,
+		r.config.ClientKeyPath,
+		r.config.CACertPath,
+		r.config.TLSServerName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS config: %w", err)
+	}
+	opts.ConnectionOptions.TLS = tlsCfg
+	cl, err := client.Dial(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating client: %w", err)
+	}
+	return cl, nil
+}
+
+func (r *Runner) handleSingleHistory(ctx context.Context, client client.Client, feature cmd.RunFeature) (err error) {
+	ctx, span := tracer.Start(ctx, "handleSingleHistory", trace.WithAttributes(
+		attribute.String("feature", feature.SummaryName()),
+	))
+	defer func() { endSpan(span, err) }()
+
 	// Obtain current history from the server even no history checking/generating
 	fetcher := history.Fetcher{
-		Client:         client,
-		Namespace:      r.config.Namespace,
-		TaskQueue:      feature.TaskQueue,
-		FeatureStarted: r.createTime,
-	}
-	storage := history.Storage{Dir: filepath.Join(r.rootDir, "features", feature.Dir, "history"), Lang: r.config.Lang}
-	// Load all histories from storage to validate against
+		Client:            client,
+		Namespace:         r.config.Namespace,
+		TaskQueue:         feature.TaskQueue,
+		TaskQueueSuffixes: r.featureTaskQueueSuffixes(feature.Dir),
+		FeatureStarted:    r.createTime,
+	}
+	storage := history.Storage{
+		Dir:     filepath.Join(r.rootDir, "features", feature.Dir, "history"),
+		Lang:    r.config.Lang,
+		Format:  r.config.HistoryFormat,
+		Variant: feature.HistoryVariant(),
+	}
+	// Load all histories of the variant from storage to validate against
 	existingSet, err := storage.Load()
 	if err != nil {
 		return err
 	}
 	if !r.config.GenerateHistory && (r.config.DisableHistoryCheck || len(existingSet.ByVersion) == 0) {
 		r.log.Info("Skipping history check since nothing to check against and not generating",
-			"Feature", feature.Dir)
+			"Feature", feature.SummaryName())
 		return nil
 	}
-	currHist, err := fetcher.Fetch(ctx)
+	fetchCtx, fetchSpan := tracer.Start(ctx, "FetchHistory")
+	currHist, err := fetcher.Fetch(fetchCtx)
+	endSpan(fetchSpan, err)
 	if err != nil {
 		return fmt.Errorf("failed getting history: %w", err)
 	}
@@ -758,46 +1348,13 @@ func (r *Runner) handleSingleHistory(ctx context.Context, client client.Client,
 	// Do a check against all scrubbed existing histories to ensure nothing
 	// changed
 	if !r.config.DisableHistoryCheck {
-
-		// Check that all versions of history match the current one when scrubbed
-		// TODO(cretz): Allow some versions to ignore histories from other versions
-		currHistScrubbed := currHist.Clone()
-		currHistScrubbed.ScrubRunSpecificFields()
-		for version, existingHist := range existingSet.ByVersion {
-			// Scrub, then check equality
-			existingHist.ScrubRunSpecificFields()
-			if !currHistScrubbed.Equals(existingHist) {
-				// Convert both to JSON because it shows a better diff
-				actualJSON, err := json.MarshalIndent(currHistScrubbed, "", "  ")
-				if err != nil {
-					return err
-				}
-				expectedJSON, err := json.MarshalIndent(existingHist, "", "  ")
-				if err != nil {
-					return err
-				}
-				// Technically, in Go, the version may be empty
-				currVersion := r.config.Version
-				if currVersion == "" {
-					currVersion = "<current>"
-				}
-				// Use the same diff lib testify assertion uses
-				diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
-					A:        difflib.SplitLines(string(expectedJSON)),
-					B:        difflib.SplitLines(string(actualJSON)),
-					FromFile: feature.Dir + "/history/history." + r.config.Lang + "." + version + ".json",
-					FromDate: "",
-					ToFile:   feature.Dir + "/history/history." + r.config.Lang + "." + currVersion + ".json",
-					ToDate:   "",
-					Context:  10,
-				})
-				// We are going to just dump this to log since it has a multiline output
-				// that Zap is not cool with in a tag
-				// TODO(cretz): Make equality output more configurable?
-				r.log.Error("History check failed, diff:\n" + diff)
-				return fmt.Errorf("on feature %v, history with current version %v didn't match version %v",
-					feature.Dir, currVersion, version)
-			}
+		_, compareSpan := tracer.Start(ctx, "CompareHistory", trace.WithAttributes(
+			attribute.Int("storedVersions", len(existingSet.ByVersion)),
+		))
+		err = r.compareHistory(feature, currHist, &storage, existingSet)
+		endSpan(compareSpan, err)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -805,12 +1362,166 @@ func (r *Runner) handleSingleHistory(ctx context.Context, client client.Client,
 	if r.config.GenerateHistory {
 		err = storage.Store(&history.StoredSet{ByVersion: map[string]history.Histories{r.config.Version: currHist}})
 		if err != nil {
-			return fmt.Errorf("failed storing history for %v: %w", feature.Dir, err)
+			return fmt.Errorf("failed storing history for %v: %w", feature.SummaryName(), err)
 		}
 	}
 	return nil
 }
 
+// compareHistory checks that all stored versions of history match the current
+// one when scrubbed.
+func (r *Runner) compareHistory(
+	feature cmd.RunFeature,
+	currHist history.Histories,
+	storage *history.Storage,
+	existingSet *history.StoredSet,
+) error {
+	// Check that all versions of history match the current one when scrubbed,
+	// unless the feature's version policy says otherwise
+	currVersion := r.currentVersion()
+	scrubRules := feature.Config.HistoryCompare.Scrub
+	currHistScrubbed := currHist.Clone()
+	currHistScrubbed.ScrubRunSpecificFields()
+	if err := currHistScrubbed.ApplyScrubRules(scrubRules); err != nil {
+		return err
+	}
+	for version, existingHist := range existingSet.ByVersion {
+		check := feature.Config.HistoryCompare.VersionCheck(r.config.Lang, currVersion, version)
+		if check == history.VersionCheckReplay && r.config.Lang != "go" {
+			// Config validation only allows this with cross-language replay
+			r.log.Info("Skipping history comparison since version policy only replays it, which only Go's "+
+				"cross-language replay does for this language", "Feature", feature.SummaryName(), "Version", version)
+			continue
+		} else if check != history.VersionCheckMatch {
+			r.log.Debug("Not comparing history because of version policy", "Feature", feature.SummaryName(),
+				"Version", version, "Check", check)
+			continue
+		}
+		// Executions are paired by their relationships, which scrubbing removes,
+		// so name them first. Then scrub and check equality.
+		existingNames, currNames := history.PairExecutions(existingHist, currHist)
+		existingHist.ScrubRunSpecificFields()
+		if err := existingHist.ApplyScrubRules(scrubRules); err != nil {
+			return err
+		}
+		if !history.EqualExecutions(existingHist, existingNames, currHistScrubbed, currNames) {
+			// Technically, the version may be unknown
+			currVersion := currVersion
+			if currVersion == "" {
+				currVersion = "<current>"
+			}
+			// The current history would be stored in the configured format as the
+			// same variant, which is the feature's variant and case joined
+			fromFile, toFile := existingSet.Files[version].Path, storage.File(currVersion)
+			diff, err := r.historyDiff(feature, fromFile, toFile,
+				existingHist, existingNames, currHistScrubbed, currNames)
+			if err != nil {
+				return err
+			}
+			// We are going to just dump this to log since it has a multiline output
+			// that Zap is not cool with in a tag
+			r.log.Error("History check failed, diff:\n" + diff)
+			return fmt.Errorf("on feature %v, history with current version %v didn't match version %v",
+				feature.SummaryName(), currVersion, version)
+		}
+	}
+	return nil
+}
+
+// featuresRelativePath returns the path relative to the features directory if
+// it is within it.
+func (r *Runner) featuresRelativePath(path string) string {
+	rel, err := filepath.Rel(filepath.Join(r.rootDir, "features"), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// currentVersion returns the SDK version being run, or empty if unknown. This
+// is the explicit version if given, or the harness SDK version for Go which
+// runs in-process without one.
+func (r *Runner) currentVersion() string {
+	if r.config.Version != "" {
+		return r.config.Version
+	} else if r.config.Lang == "go" && r.config.DirName == "" {
+		return harness.SDKVersion
+	}
+	return ""
+}
+
+// historyDiff describes how the actual scrubbed history differs from the
+// expected one in the configured format.
+func (r *Runner) historyDiff(
+	feature cmd.RunFeature,
+	expectedFile string,
+	actualFile string,
+	expected history.Histories,
+	expectedNames []string,
+	actual history.Histories,
+	actualNames []string,
+) (string, error) {
+	opts := history.DiffOptions{DecodePayload: r.payloadDecoder(feature)}
+	switch r.config.HistoryDiffFormat {
+	case HistoryDiffUnified:
+		// Convert both to JSON because it shows a better diff
+		marshal := func(h history.Histories) ([]byte, error) {
+			if opts.DecodePayload != nil {
+				return h.DecodedJSON(opts.DecodePayload)
+			}
+			return json.MarshalIndent(h, "", "  ")
+		}
+		actualJSON, err := marshal(actual)
+		if err != nil {
+			return "", err
+		}
+		expectedJSON, err := marshal(expected)
+		if err != nil {
+			return "", err
+		}
+		// Use the same diff lib testify assertion uses
+		return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(expectedJSON)),
+			B:        difflib.SplitLines(string(actualJSON)),
+			FromFile: r.featuresRelativePath(expectedFile),
+			FromDate: "",
+			ToFile:   r.featuresRelativePath(actualFile),
+			ToDate:   "",
+			Context:  10,
+		})
+	case HistoryDiffJSON:
+		return opts.DiffExecutions(expected, expectedNames, actual, actualNames).JSON()
+	default:
+		diffs := opts.DiffExecutions(expected, expectedNames, actual, actualNames)
+		if len(diffs) == 0 {
+			return "histories only differ in event IDs", nil
+		}
+		return diffs.String(), nil
+	}
+}
+
+// payloadDecoder returns the decoder for payloads in history differences of the
+// feature, or nil if payloads are not decoded. Go features use their data
+// converter if they have one. Otherwise the default data converter is used,
+// after the remote codec if configured.
+func (r *Runner) payloadDecoder(feature cmd.RunFeature) history.PayloadDecoder {
+	if !r.config.HistoryDecodePayloads {
+		return nil
+	}
+	if r.config.Lang == "go" {
+		for _, goFeature := range harness.RegisteredFeatures() {
+			if goFeature.Dir == feature.Dir && goFeature.ClientOptions.DataConverter != nil {
+				return history.DataConverterDecoder(goFeature.ClientOptions.DataConverter)
+			}
+		}
+	}
+	if r.config.HistoryCodecEndpoint != "" {
+		return history.CodecDecoder(converter.NewRemotePayloadCodec(
+			converter.RemotePayloadCodecOptions{Endpoint: r.config.HistoryCodecEndpoint}))
+	}
+	return history.DataConverterDecoder(converter.GetDefaultDataConverter())
+}
+
 // summaryServer uses the supplied listener to handle a single incoming
 // connection that sends JSONL data describing the execution status of feature
 // tests as determined by a lower level test execution harness. JSONL data items
@@ -818,24 +1529,62 @@ func (r *Runner) handleSingleHistory(ctx context.Context, client client.Client,
 //   - Name (string) the name of the test
 //   - Outcome (string) one of PASSED|FAILED|SKIPPED
 //   - Message (string) a free text field
-func (r *Runner) summaryServer(l net.Listener, out chan<- Summary) {
-	conn, err := l.Accept()
-	if err != nil {
-		// Accept returns an error if the listener is closed
+//
+// summaryServer collects summary entries from every connection made until the
+// listener is closed. Connections are read concurrently since harnesses may run
+// concurrently. The channel is closed without a value if no harness connected
+// or a connection could not be read. Every entry is passed to each of the
+// onEntry functions as soon as it is read. If harnesses may be killed after a
+// failed entry, a connection that then does not close cleanly is not an error.
+func (r *Runner) summaryServer(l net.Listener, out chan<- Summary, onEntry []func(SummaryEntry), killedOnFailure bool) {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	summary := Summary{}
+	var connected, failed bool
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			// Accept returns an error if the listener is closed
+			break
+		}
+		connected = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			entries, err := r.readSummary(conn, onEntry, killedOnFailure)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failed = true
+			}
+			summary = append(summary, entries...)
+		}()
+	}
+	wg.Wait()
+	if !connected || failed {
 		close(out)
 		return
 	}
-	summary := Summary{}
+	out <- summary
+}
+
+func (r *Runner) readSummary(conn net.Conn, onEntry []func(SummaryEntry), killedOnFailure bool) (Summary, error) {
+	var summary Summary
 	rdr := bufio.NewReaderSize(conn, 4096)
 	for {
 		line, err := rdr.ReadBytes('\n')
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				break
+				return summary, nil
+			} else if killedOnFailure && summary.anyFailed() {
+				// The harness was killed after the failure, so the connection may
+				// not have been closed cleanly
+				r.log.Debug("summary socket closed after failed feature", "error", err.Error())
+				return summary, nil
 			}
 			r.log.Error("error reading from summary socket", "error", err.Error())
-			close(out)
-			return
+			return nil, err
 		}
 		var entry SummaryEntry
 		err = json.Unmarshal(line, &entry)
@@ -844,8 +1593,10 @@ func (r *Runner) summaryServer(l net.Listener, out chan<- Summary) {
 			continue
 		}
 		summary = append(summary, entry)
+		for _, fn := range onEntry {
+			fn(entry)
+		}
 	}
-	out <- summary
 }
 
 func rootDir() string {
@@ -856,7 +1607,10 @@ func rootDir() string {
 // createNexusEndpoints creates a Nexus endpoint per RunFeature whose Dir is under
 // features/nexus, populating RunFeature.NexusEndpoint. It returns a cleanup function that
 // deletes the created endpoints. The cleanup function is always safe to call.
-func (r *Runner) createNexusEndpoints(ctx context.Context, config RunConfig, run *cmd.Run) (func(), error) {
+func (r *Runner) createNexusEndpoints(ctx context.Context, config RunConfig, run *cmd.Run) (_ func(), err error) {
+	ctx, span := tracer.Start(ctx, "createNexusEndpoints")
+	defer func() { endSpan(span, err) }()
+
 	noop := func() {}
 	var nexusFeatures []*cmd.RunFeature
 	for i := range run.Features {
@@ -996,6 +1750,15 @@ func langFlag(destination *string) *cli.StringFlag {
 	}
 }
 
+func (s Summary) anyFailed() bool {
+	for _, entry := range s {
+		if entry.Outcome == "FAILED" {
+			return true
+		}
+	}
+	return false
+}
+
 func (s Summary) Find(featureName string) (*SummaryEntry, bool) {
 	for _, entry := range s {
 		if entry.Name == featureName {
@@ -1004,3 +1767,16 @@ func (s Summary) Find(featureName string) (*SummaryEntry, bool) {
 	}
 	return nil, false
 }
+
+// FindWorst returns the entry of the feature with the worst outcome, a failure
+// over a skip over a pass. Features run repeatedly have an entry per run.
+func (s Summary) FindWorst(featureName string) (*SummaryEntry, bool) {
+	rank := map[string]int{FeaturePassed: 0, cmd.FeatureSkipped: 1, cmd.FeatureFailed: 2}
+	var worst *SummaryEntry
+	for _, entry := range s {
+		if entry.Name == featureName && (worst == nil || rank[entry.Outcome] > rank[worst.Outcome]) {
+			worst = &entry
+		}
+	}
+	return worst, worst != nil
+}
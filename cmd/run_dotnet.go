@@ -10,7 +10,9 @@ import (
 // BuildDotNetProgram prepares a .NET run without running it. The preparer
 // config directory if present is expected to be a subdirectory name just
 // beneath the root directory.
-func (p *Preparer) BuildDotNetProgram(ctx context.Context) (sdkbuild.Program, error) {
+func (p *Preparer) BuildDotNetProgram(ctx context.Context) (_ sdkbuild.Program, err error) {
+	ctx, span := p.startBuildSpan(ctx, "BuildDotNetProgram")
+	defer func() { endSpan(span, err) }()
 	p.log.Info("Building .NET project", "DirName", p.config.DirName)
 	prog, err := sdkbuild.BuildDotNetProgram(ctx, sdkbuild.BuildDotNetProgramOptions{
 		BaseDir:         p.rootDir,
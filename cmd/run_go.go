@@ -11,7 +11,9 @@ import (
 // BuildGoProgram prepares a Go run without running it. The preparer config
 // directory if present is expected to be a subdirectory name just beneath the
 // root directory.
-func (p *Preparer) BuildGoProgram(ctx context.Context) (sdkbuild.Program, error) {
+func (p *Preparer) BuildGoProgram(ctx context.Context) (_ sdkbuild.Program, err error) {
+	ctx, span := p.startBuildSpan(ctx, "BuildGoProgram")
+	defer func() { endSpan(span, err) }()
 	p.log.Info("Building Go project", "DirName", p.config.DirName)
 	prog, err := sdkbuild.BuildGoProgram(ctx, sdkbuild.BuildGoProgramOptions{
 		BaseDir: p.rootDir,
@@ -74,6 +76,12 @@ func (r *Runner) RunGoExternal(ctx context.Context, run *cmd.Run) error {
 	if r.config.TLSServerName != "" {
 		args = append(args, "--tls-server-name", r.config.TLSServerName)
 	}
+	if r.config.FailFast {
+		args = append(args, "--fail-fast")
+	}
+	if r.config.ReplayOnly {
+		args = append(args, "--replay-only")
+	}
 	args = append(args, run.ToArgs()...)
 	cmd, err := r.program.NewCommand(ctx, args...)
 	if err == nil {
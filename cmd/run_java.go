@@ -12,7 +12,9 @@ import (
 // BuildJavaProgram prepares a Java run without running it. The preparer config
 // directory if present is expected to be a subdirectory name just beneath the
 // root directory.
-func (p *Preparer) BuildJavaProgram(ctx context.Context, build bool) (sdkbuild.Program, error) {
+func (p *Preparer) BuildJavaProgram(ctx context.Context, build bool) (_ sdkbuild.Program, err error) {
+	ctx, span := p.startBuildSpan(ctx, "BuildJavaProgram")
+	defer func() { endSpan(span, err) }()
 	p.log.Info("Building Java project", "DirName", p.config.DirName)
 	prog, err := sdkbuild.BuildJavaProgram(ctx, sdkbuild.BuildJavaProgramOptions{
 		BaseDir:           p.rootDir,
@@ -12,7 +12,9 @@ import (
 // PreparePhpExternal prepares a PHP run without running it. The preparer
 // config directory if present is expected to be a subdirectory name just
 // beneath the root directory.
-func (p *Preparer) BuildPhpProgram(ctx context.Context) (sdkbuild.Program, error) {
+func (p *Preparer) BuildPhpProgram(ctx context.Context) (_ sdkbuild.Program, err error) {
+	ctx, span := p.startBuildSpan(ctx, "BuildPhpProgram")
+	defer func() { endSpan(span, err) }()
 	p.log.Info("Building PHP project", "DirName", p.config.DirName)
 
 	prog, err := sdkbuild.BuildPhpProgram(ctx, sdkbuild.BuildPhpProgramOptions{
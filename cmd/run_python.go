@@ -14,7 +14,9 @@ import (
 // PreparePythonExternal prepares a Python run without running it. The preparer
 // config directory if present is expected to be a subdirectory name just
 // beneath the root directory.
-func (p *Preparer) BuildPythonProgram(ctx context.Context) (sdkbuild.Program, error) {
+func (p *Preparer) BuildPythonProgram(ctx context.Context) (_ sdkbuild.Program, err error) {
+	ctx, span := p.startBuildSpan(ctx, "BuildPythonProgram")
+	defer func() { endSpan(span, err) }()
 	p.log.Info("Building Python project", "DirName", p.config.DirName)
 
 	// Get version from pyproject.toml if not present.
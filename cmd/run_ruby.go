@@ -14,7 +14,9 @@ import (
 // BuildRubyProgram prepares a Ruby run without running it. The preparer
 // config directory if present is expected to be a subdirectory name just
 // beneath the root directory.
-func (p *Preparer) BuildRubyProgram(ctx context.Context) (sdkbuild.Program, error) {
+func (p *Preparer) BuildRubyProgram(ctx context.Context) (_ sdkbuild.Program, err error) {
+	ctx, span := p.startBuildSpan(ctx, "BuildRubyProgram")
+	defer func() { endSpan(span, err) }()
 	p.log.Info("Building Ruby project", "DirName", p.config.DirName)
 
 	// Get version from harness/ruby/Gemfile if not present.
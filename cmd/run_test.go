@@ -2,12 +2,30 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	_ "github.com/temporalio/features/features"
 	hcmd "github.com/temporalio/features/harness/go/cmd"
+	"github.com/temporalio/features/harness/go/harness"
+	"github.com/temporalio/features/harness/go/history"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestDynamicConfigArgsAppliesOverrides(t *testing.T) {
@@ -89,16 +107,250 @@ func TestMakeRunBatchesExpandsVariants(t *testing.T) {
 	}
 }
 
+func TestMakeRunBatchesShufflesWithSeed(t *testing.T) {
+	var features []*RunFeature
+	for _, dir := range []string{"activity/basic", "signal/basic", "timer/basic", "update/basic", "query/basic"} {
+		features = append(features, &RunFeature{Dir: dir})
+	}
+	order := func(seed int64) []string {
+		r := NewRunner(RunConfig{})
+		r.shuffleRand = rand.New(rand.NewSource(seed))
+		batches := r.makeRunBatches(features)
+		if len(batches) != 1 {
+			t.Fatalf("expected 1 batch, got %d", len(batches))
+		}
+		var dirs []string
+		for _, feature := range batches[0].Run.Features {
+			dirs = append(dirs, feature.Dir)
+		}
+		return dirs
+	}
+
+	first := order(42)
+	if len(first) != len(features) {
+		t.Fatalf("shuffled features = %v", first)
+	}
+	if second := order(42); !reflect.DeepEqual(first, second) {
+		t.Fatalf("same seed gave different orders: %v and %v", first, second)
+	}
+	sorted := append([]string(nil), first...)
+	sort.Strings(sorted)
+	if !reflect.DeepEqual(sorted, []string{"activity/basic", "query/basic", "signal/basic", "timer/basic", "update/basic"}) {
+		t.Fatalf("shuffle lost or duplicated features: %v", first)
+	}
+}
+
+func TestMakeRunBatchesRepeatsWithCount(t *testing.T) {
+	r := NewRunner(RunConfig{Count: 3})
+	batches := r.makeRunBatches([]*RunFeature{{Dir: "activity/basic"}, {Dir: "timer/basic"}})
+	if len(batches) != 1 {
+		t.Fatalf("expected 1 batch, got %d", len(batches))
+	}
+	var dirs []string
+	taskQueues := map[string]bool{}
+	for _, feature := range batches[0].Run.Features {
+		dirs = append(dirs, feature.Dir)
+		taskQueues[feature.TaskQueue] = true
+	}
+	expected := []string{"activity/basic", "activity/basic", "activity/basic", "timer/basic", "timer/basic", "timer/basic"}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Fatalf("repeated features = %v", dirs)
+	}
+	if len(taskQueues) != len(expected) {
+		t.Fatalf("expected a fresh task queue per repetition, got %v", taskQueues)
+	}
+}
+
 func TestRewriteVariantSummary(t *testing.T) {
 	features := []hcmd.RunFeature{
 		{Dir: "worker_shutdown/poll_complete_on_shutdown", VariantName: "enabled"},
+		{Dir: "activity/cancellation", VariantName: "enabled", CaseName: "abandon"},
 	}
 	summary := rewriteVariantSummary(Summary{
 		{Name: "worker_shutdown/poll_complete_on_shutdown", Outcome: FeaturePassed},
+		{Name: "worker_shutdown/poll_complete_on_shutdown/cross-language-replay", Outcome: FeaturePassed},
+		{Name: "activity/cancellation+abandon", Outcome: FeaturePassed},
 	}, features)
 	if got := summary[0].Name; got != "worker_shutdown/poll_complete_on_shutdown#enabled" {
 		t.Fatalf("summary name = %q", got)
 	}
+	if got := summary[1].Name; got != "worker_shutdown/poll_complete_on_shutdown#enabled/cross-language-replay" {
+		t.Fatalf("cross-language replay summary name = %q", got)
+	}
+	if got := summary[2].Name; got != "activity/cancellation#enabled+abandon" {
+		t.Fatalf("variant and case summary name = %q", got)
+	}
+}
+
+func TestSummaryFindWorstOfRepeatedFeature(t *testing.T) {
+	summary := Summary{
+		{Name: "activity/basic", Outcome: FeaturePassed},
+		{Name: "activity/basic", Outcome: hcmd.FeatureFailed, Message: "boom"},
+		{Name: "activity/basic", Outcome: hcmd.FeatureSkipped},
+		{Name: "timer/basic", Outcome: FeaturePassed},
+	}
+	if entry, ok := summary.FindWorst("activity/basic"); !ok || entry.Outcome != hcmd.FeatureFailed || entry.Message != "boom" {
+		t.Fatalf("worst of activity/basic = %+v", entry)
+	}
+	if entry, ok := summary.FindWorst("timer/basic"); !ok || entry.Outcome != FeaturePassed {
+		t.Fatalf("worst of timer/basic = %+v", entry)
+	}
+	if _, ok := summary.FindWorst("missing"); ok {
+		t.Fatal("found missing feature")
+	}
+}
+
+func TestSummaryServerReportsFailureAsRead(t *testing.T) {
+	l, err := net.Listen("tcp", summaryListenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	failed := make(chan struct{}, 1)
+	summaryChan := make(chan Summary)
+	r := NewRunner(RunConfig{})
+	onEntry := func(entry SummaryEntry) {
+		if entry.Outcome == hcmd.FeatureFailed {
+			failed <- struct{}{}
+		}
+	}
+	go r.summaryServer(l, summaryChan, []func(SummaryEntry){onEntry}, true)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(conn, `{"name":"activity/basic","outcome":"PASSED"}`)
+	fmt.Fprintln(conn, `{"name":"timer/basic","outcome":"FAILED","message":"boom"}`)
+	// The failure is reported while the harness is still connected, so it can be
+	// killed before running more features
+	<-failed
+	conn.Close()
+	l.Close()
+	summary, ok := <-summaryChan
+	if !ok || len(summary) != 2 || !summary.anyFailed() {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
+
+func TestFailFastStopsHarnessesBeforeFinishingLeakCheck(t *testing.T) {
+	l, err := net.Listen("tcp", summaryListenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	r := NewRunner(RunConfig{})
+	var events []string
+	hooks := batchHooks{
+		onSummaryEntry:  []func(SummaryEntry){func(entry SummaryEntry) { events = append(events, "entry "+entry.Name) }},
+		onHarnessesExit: []func(){func() { events = append(events, "leak check finished") }},
+	}
+	// The harness reports a failure then runs until it is killed
+	summary, received, harnessErr := r.runHarnessesWithHooks(context.Background(), l, true, hooks,
+		func(ctx context.Context) error {
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			fmt.Fprintln(conn, `{"name":"activity/basic","outcome":"PASSED"}`)
+			fmt.Fprintln(conn, `{"name":"timer/basic","outcome":"FAILED","message":"boom"}`)
+			<-ctx.Done()
+			events = append(events, "harness killed")
+			return ctx.Err()
+		})
+	if harnessErr != nil {
+		t.Fatalf("harness error = %v", harnessErr)
+	}
+	if !received || len(summary) != 2 || !summary.anyFailed() {
+		t.Fatalf("summary = %+v", summary)
+	}
+	expected := []string{"entry activity/basic", "entry timer/basic", "harness killed", "leak check finished"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("events = %v, expected %v", events, expected)
+	}
+}
+
+func TestLeakCheckWaitsForAllRepetitionsOfFeature(t *testing.T) {
+	l := newLeakCheck(context.Background(), NewRunner(RunConfig{}), nil, &hcmd.Run{Features: []hcmd.RunFeature{
+		{Dir: "activity/basic", TaskQueue: "tq1"},
+		{Dir: "timer/basic", TaskQueue: "tq2"},
+		{Dir: "activity/basic", TaskQueue: "tq3"},
+	}})
+	if features := l.reported("activity/basic"); len(features) != 0 {
+		t.Fatalf("checked %+v before all repetitions reported", features)
+	}
+	if features := l.reported("timer/basic"); len(features) != 1 || features[0].TaskQueue != "tq2" {
+		t.Fatalf("timer/basic checked %+v", features)
+	}
+	if features := l.reported("activity/basic"); len(features) != 2 {
+		t.Fatalf("activity/basic checked %+v", features)
+	}
+	// Entries of no feature of the run, or already checked, check nothing
+	if features := l.reported("activity/basic/cross-language-replay"); len(features) != 0 {
+		t.Fatalf("cross-language replay checked %+v", features)
+	}
+	if features := l.reported("timer/basic"); len(features) != 0 {
+		t.Fatalf("timer/basic checked again %+v", features)
+	}
+	if len(l.pending) != 0 {
+		t.Fatalf("features left unchecked: %+v", l.pending)
+	}
+}
+
+// leakTestClient has no workflows and, on every task queue, a poller that
+// stopped before the feature finished and a worker left running in a long poll
+// begun before the feature finished that polls again after.
+type leakTestClient struct {
+	client.Client
+	finished time.Time
+	lock     sync.Mutex
+	describe map[string]int
+}
+
+func (c *leakTestClient) ListWorkflow(
+	context.Context,
+	*workflowservice.ListWorkflowExecutionsRequest,
+) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	return &workflowservice.ListWorkflowExecutionsResponse{}, nil
+}
+
+func (c *leakTestClient) DescribeTaskQueue(
+	_ context.Context,
+	taskQueue string,
+	taskQueueType enums.TaskQueueType,
+) (*workflowservice.DescribeTaskQueueResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := taskQueue + "/" + taskQueueType.String()
+	c.describe[key]++
+	runningAccess := c.finished.Add(-time.Second)
+	if c.describe[key] > 1 {
+		runningAccess = time.Now()
+	}
+	return &workflowservice.DescribeTaskQueueResponse{Pollers: []*taskqueue.PollerInfo{
+		{Identity: "stopped", LastAccessTime: timestamppb.New(c.finished.Add(-time.Second))},
+		{Identity: "running", LastAccessTime: timestamppb.New(runningAccess)},
+	}}, nil
+}
+
+func TestFindFeatureLeaksReportsWorkerLeftPolling(t *testing.T) {
+	defer func(interval time.Duration) { leakPollInterval = interval }(leakPollInterval)
+	leakPollInterval = 100 * time.Millisecond
+	cl := &leakTestClient{finished: time.Now(), describe: map[string]int{}}
+	r := NewRunner(RunConfig{})
+	leaks, err := r.findFeatureLeaks(context.Background(), cl, hcmd.RunFeature{Dir: "activity/basic", TaskQueue: "tq"}, cl.finished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaks.Running) != 0 || len(leaks.Pollers) != len(leakCheckTaskQueueTypes) {
+		t.Fatalf("leaks = %+v", leaks)
+	}
+	for _, poller := range leaks.Pollers {
+		if poller.TaskQueue != "tq" || poller.Identity != "running" || !poller.LastAccessTime.After(cl.finished) {
+			t.Fatalf("leaked poller = %+v", poller)
+		}
+	}
 }
 
 func TestRunBatchRejectsVariantWithExternalServer(t *testing.T) {
@@ -185,3 +437,98 @@ func TestSetNamespaceCapabilitiesEnvForInProcessGoRunRestoresPreviousValues(t *t
 		t.Fatalf("capabilities env after restore = %q, want old", got)
 	}
 }
+
+func TestReplayOnlyRequiresStoredHistory(t *testing.T) {
+	r := NewRunner(RunConfig{PrepareConfig: PrepareConfig{Lang: "go"}, ReplayOnly: true})
+	r.rootDir = t.TempDir()
+	err := r.replayOnly(context.Background(), []*RunFeature{{Dir: "activity/basic"}})
+	if err == nil || !strings.Contains(err.Error(), "no matched features have stored history") {
+		t.Fatalf("expected missing history error, got %v", err)
+	}
+}
+
+func TestHistoryToGenerateListsEachFeatureAndVariant(t *testing.T) {
+	r := NewRunner(RunConfig{PrepareConfig: PrepareConfig{Lang: "go", Version: "v1.30.0"}, HistoryFormat: "json"})
+	r.rootDir = t.TempDir()
+	historyDir := filepath.Join(r.rootDir, "features", "activity", "basic", "history")
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(historyDir, "history.go.v1.30.0.binpb"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	toGenerate, err := r.historyToGenerate([]*RunFeature{
+		{Dir: "activity/basic"},
+		{Dir: "activity/no_workflow", Config: hcmd.RunFeatureConfig{NoWorkflow: true}},
+		{Dir: "worker/shutdown", Config: hcmd.RunFeatureConfig{RunVariants: []hcmd.RunVariantConfig{{Name: "enabled"}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []generatedHistory{
+		{
+			Feature:  "activity/basic",
+			File:     filepath.Join(historyDir, "history.go.v1.30.0.json"),
+			Existing: filepath.Join(historyDir, "history.go.v1.30.0.binpb"),
+		},
+		{
+			Feature: "worker/shutdown#enabled",
+			File:    filepath.Join(r.rootDir, "features", "worker", "shutdown", "history", "history.go.enabled.v1.30.0.json"),
+		},
+	}
+	if !reflect.DeepEqual(toGenerate, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, toGenerate)
+	}
+}
+
+func TestHistoryDiffUnifiedNamesStoredAndCurrentFiles(t *testing.T) {
+	r := NewRunner(RunConfig{
+		PrepareConfig:     PrepareConfig{Lang: "go"},
+		HistoryDiffFormat: HistoryDiffUnified,
+		HistoryFormat:     history.FormatBinary,
+	})
+	feature := hcmd.RunFeature{Dir: "activity/basic", VariantName: "polls-enabled", CaseName: "retry"}
+	storage := history.Storage{
+		Dir:     filepath.Join(r.rootDir, "features", feature.Dir, "history"),
+		Lang:    r.config.Lang,
+		Format:  r.config.HistoryFormat,
+		Variant: feature.HistoryVariant(),
+	}
+	expected := history.Histories{{Events: []*historypb.HistoryEvent{{EventId: 1}}}}
+	actual := history.Histories{{Events: []*historypb.HistoryEvent{{EventId: 2}}}}
+	diff, err := r.historyDiff(feature, filepath.Join(storage.Dir, "history.go.polls-enabled+retry.v1.2.3.json.gz"),
+		storage.File("v1.3.0"), expected, []string{"wf"}, actual, []string{"wf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "--- activity/basic/history/history.go.polls-enabled+retry.v1.2.3.json.gz\n") ||
+		!strings.Contains(diff, "+++ activity/basic/history/history.go.polls-enabled+retry.v1.3.0.binpb\n") {
+		t.Fatalf("diff = %v", diff)
+	}
+}
+
+func TestPayloadDecoderUsesGoFeatureDataConverter(t *testing.T) {
+	r := NewRunner(RunConfig{PrepareConfig: PrepareConfig{Lang: "go"}})
+	if r.payloadDecoder(hcmd.RunFeature{Dir: "data_converter/codec"}) != nil {
+		t.Fatal("expected no decoder when not decoding payloads")
+	}
+	r.config.HistoryDecodePayloads = true
+	var dc converter.DataConverter
+	for _, feature := range harness.RegisteredFeatures() {
+		if feature.Dir == "data_converter/codec" {
+			dc = feature.ClientOptions.DataConverter
+		}
+	}
+	payload, err := dc.ToPayload("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded := r.payloadDecoder(hcmd.RunFeature{Dir: "data_converter/codec"})(payload); decoded != `"hello"` {
+		t.Fatalf("expected decoded value, got %v", decoded)
+	}
+	// Other features fall back to the default converter, which can't decode it
+	if decoded := r.payloadDecoder(hcmd.RunFeature{Dir: "activity/basic"})(payload); decoded == `"hello"` {
+		t.Fatalf("expected default converter to not decode codec payload")
+	}
+}
@@ -14,7 +14,9 @@ import (
 // BuildTypeScriptProgram prepares a TypeScript run without running it. The
 // preparer config directory if present is expected to be a subdirectory name
 // just beneath the root directory.
-func (p *Preparer) BuildTypeScriptProgram(ctx context.Context) (sdkbuild.Program, error) {
+func (p *Preparer) BuildTypeScriptProgram(ctx context.Context) (_ sdkbuild.Program, err error) {
+	ctx, span := p.startBuildSpan(ctx, "BuildTypeScriptProgram")
+	defer func() { endSpan(span, err) }()
 	p.log.Info("Building TypeScript project", "DirName", p.config.DirName)
 
 	// Get version from package.json if not present
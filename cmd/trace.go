@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/temporalio/features/cmd"
+
+// tracer is used for all runner and preparer spans. It delegates to the global
+// tracer provider, so it is a no-op unless startTracing has been called.
+var tracer = otel.Tracer(tracerName)
+
+// startTracing installs a global tracer provider that exports all spans to the
+// given file in OTLP-JSON form. The returned function flushes and closes the
+// file and must be called before exit.
+func startTracing(ctx context.Context, path string) (func(), error) {
+	exporter, err := newOTLPJSONFileExporter(path)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", "temporal-features"),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed creating trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	return func() {
+		// Use a fresh context so an already-canceled run still flushes spans
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		_ = provider.Shutdown(shutdownCtx)
+		otel.SetTracerProvider(prevProvider)
+	}, nil
+}
+
+// endSpan records the error on the span if non-nil and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// otlpJSONFileExporter writes each exported batch of spans as a single line of
+// OTLP-JSON TracesData, matching the OpenTelemetry file exporter format.
+type otlpJSONFileExporter struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+var _ sdktrace.SpanExporter = (*otlpJSONFileExporter)(nil)
+
+func newOTLPJSONFileExporter(path string) (*otlpJSONFileExporter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating trace file: %w", err)
+	}
+	return &otlpJSONFileExporter{file: file}, nil
+}
+
+func (e *otlpJSONFileExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(spansToTracesData(spans))
+	if err != nil {
+		return fmt.Errorf("failed marshaling spans: %w", err)
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.file == nil {
+		return fmt.Errorf("exporter is shut down")
+	}
+	_, err = e.file.Write(append(b, '\n'))
+	return err
+}
+
+func (e *otlpJSONFileExporter) Shutdown(context.Context) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.file == nil {
+		return nil
+	}
+	err := e.file.Close()
+	e.file = nil
+	return err
+}
+
+// The otlp* types are the OTLP-JSON form of the OTLP trace protos. Unlike the
+// standard protobuf JSON mapping, OTLP-JSON has hex trace and span IDs and
+// integer enums. As in the protobuf JSON mapping, 64-bit integers are strings.
+// The Go SDK has no exporter that writes this form to a file.
+
+type otlpTracesData struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+	SchemaURL  string            `json:"schemaUrl,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope     otlpScope   `json:"scope"`
+	Spans     []*otlpSpan `json:"spans"`
+	SchemaURL string      `json:"schemaUrl,omitempty"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID                string         `json:"traceId"`
+	SpanID                 string         `json:"spanId"`
+	TraceState             string         `json:"traceState,omitempty"`
+	ParentSpanID           string         `json:"parentSpanId,omitempty"`
+	Name                   string         `json:"name"`
+	Kind                   trace.SpanKind `json:"kind"`
+	StartTimeUnixNano      uint64         `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano        uint64         `json:"endTimeUnixNano,string"`
+	Attributes             []otlpKeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int            `json:"droppedAttributesCount,omitempty"`
+	Events                 []otlpEvent    `json:"events,omitempty"`
+	DroppedEventsCount     int            `json:"droppedEventsCount,omitempty"`
+	Links                  []otlpLink     `json:"links,omitempty"`
+	DroppedLinksCount      int            `json:"droppedLinksCount,omitempty"`
+	Status                 otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano           uint64         `json:"timeUnixNano,string"`
+	Name                   string         `json:"name"`
+	Attributes             []otlpKeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int            `json:"droppedAttributesCount,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID                string         `json:"traceId"`
+	SpanID                 string         `json:"spanId"`
+	TraceState             string         `json:"traceState,omitempty"`
+	Attributes             []otlpKeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int            `json:"droppedAttributesCount,omitempty"`
+}
+
+// OTLP status codes, which differ from codes.Code.
+const (
+	otlpStatusCodeUnset = 0
+	otlpStatusCodeOK    = 1
+	otlpStatusCodeError = 2
+)
+
+type otlpStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue has exactly one field set.
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *int64          `json:"intValue,omitempty,string"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+func spansToTracesData(spans []sdktrace.ReadOnlySpan) *otlpTracesData {
+	// Group by resource, then by scope, preserving first-seen order
+	data := &otlpTracesData{}
+	resourceIndex := map[attribute.Distinct]*otlpResourceSpans{}
+	scopeIndex := map[attribute.Distinct]map[instrumentation.Scope]*otlpScopeSpans{}
+	for _, span := range spans {
+		res := span.Resource()
+		resKey := res.Equivalent()
+		rs, ok := resourceIndex[resKey]
+		if !ok {
+			rs = &otlpResourceSpans{
+				Resource:  otlpResource{Attributes: attributesToKeyValues(res.Attributes())},
+				SchemaURL: res.SchemaURL(),
+			}
+			resourceIndex[resKey] = rs
+			scopeIndex[resKey] = map[instrumentation.Scope]*otlpScopeSpans{}
+			data.ResourceSpans = append(data.ResourceSpans, rs)
+		}
+		scope := span.InstrumentationScope()
+		ss, ok := scopeIndex[resKey][scope]
+		if !ok {
+			ss = &otlpScopeSpans{
+				Scope:     otlpScope{Name: scope.Name, Version: scope.Version},
+				SchemaURL: scope.SchemaURL,
+			}
+			scopeIndex[resKey][scope] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, spanToOTLP(span))
+	}
+	return data
+}
+
+func spanToOTLP(span sdktrace.ReadOnlySpan) *otlpSpan {
+	spanCtx := span.SpanContext()
+	ret := &otlpSpan{
+		TraceID:                spanCtx.TraceID().String(),
+		SpanID:                 spanCtx.SpanID().String(),
+		TraceState:             spanCtx.TraceState().String(),
+		Name:                   span.Name(),
+		Kind:                   span.SpanKind(),
+		StartTimeUnixNano:      uint64(span.StartTime().UnixNano()),
+		EndTimeUnixNano:        uint64(span.EndTime().UnixNano()),
+		Attributes:             attributesToKeyValues(span.Attributes()),
+		DroppedAttributesCount: span.DroppedAttributes(),
+		DroppedEventsCount:     span.DroppedEvents(),
+		DroppedLinksCount:      span.DroppedLinks(),
+	}
+	if parent := span.Parent(); parent.IsValid() {
+		ret.ParentSpanID = parent.SpanID().String()
+	}
+	for _, event := range span.Events() {
+		ret.Events = append(ret.Events, otlpEvent{
+			TimeUnixNano:           uint64(event.Time.UnixNano()),
+			Name:                   event.Name,
+			Attributes:             attributesToKeyValues(event.Attributes),
+			DroppedAttributesCount: event.DroppedAttributeCount,
+		})
+	}
+	for _, link := range span.Links() {
+		ret.Links = append(ret.Links, otlpLink{
+			TraceID:                link.SpanContext.TraceID().String(),
+			SpanID:                 link.SpanContext.SpanID().String(),
+			TraceState:             link.SpanContext.TraceState().String(),
+			Attributes:             attributesToKeyValues(link.Attributes),
+			DroppedAttributesCount: link.DroppedAttributeCount,
+		})
+	}
+	status := span.Status()
+	ret.Status = otlpStatus{Message: status.Description, Code: otlpStatusCodeUnset}
+	switch status.Code {
+	case codes.Ok:
+		ret.Status.Code = otlpStatusCodeOK
+	case codes.Error:
+		ret.Status.Code = otlpStatusCodeError
+	}
+	return ret
+}
+
+func attributesToKeyValues(attrs []attribute.KeyValue) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	ret := make([]otlpKeyValue, len(attrs))
+	for i, attr := range attrs {
+		ret[i] = otlpKeyValue{Key: string(attr.Key), Value: attributeValueToOTLP(attr.Value)}
+	}
+	return ret
+}
+
+func attributeValueToOTLP(v attribute.Value) otlpAnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpAnyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := v.AsInt64()
+		return otlpAnyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpAnyValue{DoubleValue: &f}
+	case attribute.STRING:
+		s := v.AsString()
+		return otlpAnyValue{StringValue: &s}
+	case attribute.BOOLSLICE:
+		return arrayValueToOTLP(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return arrayValueToOTLP(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return arrayValueToOTLP(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return arrayValueToOTLP(v.AsStringSlice(), attribute.StringValue)
+	default:
+		s := v.Emit()
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func arrayValueToOTLP[T any](values []T, toValue func(T) attribute.Value) otlpAnyValue {
+	arr := &otlpArrayValue{Values: make([]otlpAnyValue, len(values))}
+	for i, value := range values {
+		arr.Values[i] = attributeValueToOTLP(toValue(value))
+	}
+	return otlpAnyValue{ArrayValue: arr}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceFileWritesOTLPJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	stopTracing, err := startTracing(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := tracer.Start(context.Background(), "parent", trace.WithAttributes(attribute.Int("count", 3)))
+	_, child := tracer.Start(ctx, "child")
+	endSpan(child, errors.New("child failed"))
+	endSpan(parent, nil)
+	stopTracing()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decode the raw JSON since the protobuf JSON mapping differs from OTLP-JSON
+	// in how IDs and enums are encoded
+	type otlpSpan struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+		Kind         int    `json:"kind"`
+		Attributes   []struct {
+			Key   string `json:"key"`
+			Value struct {
+				IntValue string `json:"intValue"`
+			} `json:"value"`
+		} `json:"attributes"`
+		Status struct {
+			Code int `json:"code"`
+		} `json:"status"`
+	}
+	var data struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	spans := map[string]otlpSpan{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+			t.Fatalf("invalid OTLP-JSON line %q: %v", scanner.Text(), err)
+		}
+		for _, rs := range data.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				if ss.Scope.Name != tracerName {
+					t.Fatalf("scope = %q, want %q", ss.Scope.Name, tracerName)
+				}
+				for _, span := range ss.Spans {
+					spans[span.Name] = span
+				}
+			}
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	hexID := regexp.MustCompile(`^[0-9a-f]+$`)
+	for name, span := range spans {
+		if len(span.TraceID) != 32 || !hexID.MatchString(span.TraceID) {
+			t.Fatalf("%v trace ID = %q, want 32 hex digits", name, span.TraceID)
+		} else if len(span.SpanID) != 16 || !hexID.MatchString(span.SpanID) {
+			t.Fatalf("%v span ID = %q, want 16 hex digits", name, span.SpanID)
+		} else if span.Kind != 1 {
+			t.Fatalf("%v kind = %v, want %v", name, span.Kind, 1)
+		}
+	}
+	if spans["child"].ParentSpanID != spans["parent"].SpanID {
+		t.Fatalf("child parent span ID = %v, want %v", spans["child"].ParentSpanID, spans["parent"].SpanID)
+	}
+	if spans["child"].TraceID != spans["parent"].TraceID {
+		t.Fatalf("child trace ID = %v, want %v", spans["child"].TraceID, spans["parent"].TraceID)
+	}
+	// 64-bit integers are strings
+	if attrs := spans["parent"].Attributes; len(attrs) != 1 || attrs[0].Key != "count" || attrs[0].Value.IntValue != "3" {
+		t.Fatalf("parent attributes = %+v", attrs)
+	}
+	if spans["child"].Status.Code != otlpStatusCodeError {
+		t.Fatalf("child status = %v, want %v", spans["child"].Status.Code, otlpStatusCodeError)
+	}
+}
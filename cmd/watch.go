@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/features/sdkbuild"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+)
+
+const watchPollInterval = 500 * time.Millisecond
+
+// compiledSourceExts are, per language, the file extensions that require the
+// prepared program to be rebuilt when changed. Languages not present here read
+// their sources directly at run time (or, for Java, compile as part of running)
+// and never need an explicit rebuild.
+var compiledSourceExts = map[string][]string{
+	"go": {".go"},
+	"ts": {".ts"},
+	"cs": {".cs"},
+}
+
+// harnessDirs are the harness source directories, relative to the harness
+// directory, that each language depends on.
+var harnessDirs = map[string]string{
+	"go":   "go",
+	"java": "java",
+	"ts":   "ts",
+	"php":  "php",
+	"py":   "python",
+	"cs":   "dotnet",
+	"rb":   "ruby",
+}
+
+type watchedFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchChanges is the set of changes seen since the last run.
+type watchChanges struct {
+	// Feature dirs whose files changed
+	features map[string]bool
+	// Whether harness sources changed, which affects every feature
+	harness bool
+	// Whether files beneath the features dir but outside any feature dir
+	// changed, such as shared helper packages, which may affect every feature
+	shared bool
+	// Whether any changed file requires rebuilding the program
+	rebuild bool
+	paths   []string
+}
+
+// watch runs the given features, then keeps the server and prepared program
+// alive and re-runs affected features whenever their directories, shared
+// feature sources, or the harness sources change. It only returns when the
+// context is done or on ctrl-c.
+func (r *Runner) watch(ctx context.Context, patterns []string, features []*RunFeature) error {
+	// Variants each need a server with their own dynamic config, but watching
+	// shares one server across runs
+	for _, feature := range features {
+		if len(feature.Config.RunVariants) > 0 {
+			return fmt.Errorf("cannot watch feature %q because it defines runVariants", feature.Dir)
+		}
+	}
+
+	// Stop on ctrl-c by canceling the context instead of exiting so the server
+	// and prepared program are cleaned up on return
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start one server for the whole session instead of one per batch. Batches
+	// then treat it as an external server.
+	if r.config.Server == "" {
+		if r.config.Namespace == "" {
+			r.config.Namespace = "features-ns-" + uuid.NewString()
+		}
+		dynamicConfigArgs, err := r.dynamicConfigArgs(nil)
+		if err != nil {
+			return err
+		}
+		server, err := testsuite.StartDevServer(ctx, testsuite.DevServerOptions{
+			LogLevel:      "error",
+			ClientOptions: &client.Options{Namespace: r.config.Namespace},
+			ExtraArgs:     dynamicConfigArgs,
+		})
+		if err != nil {
+			return fmt.Errorf("failed starting devserver: %w", err)
+		}
+		defer server.Stop()
+		r.config.Server = server.FrontendHostPort()
+		r.log.Info("Started server for watch", "HostPort", r.config.Server)
+	}
+
+	// Go runs in-process by default, which cannot pick up source changes, so
+	// build a program up front that can be rebuilt on change
+	if r.config.Lang == "go" && r.config.DirName == "" && r.program == nil {
+		var err error
+		if r.program, err = NewPreparer(r.config.PrepareConfig).BuildGoProgram(ctx); err != nil {
+			return err
+		}
+	}
+
+	// The whole features dir is watched since features can import shared
+	// helpers outside their own dir
+	roots := []string{
+		filepath.Join(r.rootDir, "harness", harnessDirs[r.config.Lang]),
+		filepath.Join(r.rootDir, "features"),
+	}
+	snapshot := snapshotFiles(roots)
+
+	r.runWatched(ctx, features)
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	var pending *watchChanges
+	for {
+		select {
+		case <-ctx.Done():
+			r.log.Info("Stopping watch")
+			return nil
+		case <-ticker.C:
+		}
+		newSnapshot := snapshotFiles(roots)
+		changed := diffSnapshots(snapshot, newSnapshot)
+		snapshot = newSnapshot
+		if len(changed) > 0 {
+			// Accumulate and wait for the files to settle before acting
+			if pending == nil {
+				pending = &watchChanges{features: map[string]bool{}}
+			}
+			r.classifyChanges(pending, changed)
+			continue
+		} else if pending == nil {
+			continue
+		}
+
+		changes := pending
+		pending = nil
+		r.log.Info("Detected changes", "Paths", changes.paths)
+		if changes.rebuild {
+			if err := r.rebuildProgram(ctx); err != nil {
+				r.log.Error("Rebuild failed, waiting for more changes", "error", err)
+				continue
+			}
+		}
+		// Reload features so config changes are seen, keeping only affected ones
+		reloaded, err := r.GlobFeatures(patterns)
+		if err != nil {
+			r.log.Error("Failed reloading features, waiting for more changes", "error", err)
+			continue
+		}
+		var affected []*RunFeature
+		for _, feature := range reloaded {
+			if changes.harness || changes.shared || changes.features[feature.Dir] {
+				affected = append(affected, feature)
+			}
+		}
+		r.runWatched(ctx, affected)
+	}
+}
+
+// runWatched runs the given features once, logging instead of returning any
+// failure so watching can continue.
+func (r *Runner) runWatched(ctx context.Context, features []*RunFeature) {
+	if len(features) == 0 {
+		r.log.Info("No watched features affected by changes")
+		return
+	}
+	for _, batch := range r.makeRunBatches(features) {
+		if ctx.Err() != nil {
+			return
+		} else if err := r.runBatch(ctx, batch); err != nil && ctx.Err() == nil {
+			r.log.Error("Watched run failed", "error", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	fmt.Println("Watching for changes, press Ctrl+C to stop")
+}
+
+// classifyChanges adds the given changed paths to the set of changes.
+func (r *Runner) classifyChanges(changes *watchChanges, paths []string) {
+	harnessDir := filepath.Join(r.rootDir, "harness", harnessDirs[r.config.Lang])
+	featuresDir := filepath.Join(r.rootDir, "features")
+	for _, path := range paths {
+		changes.paths = append(changes.paths, path)
+		if r.isCompiledSource(path) {
+			changes.rebuild = true
+		}
+		if rel, err := filepath.Rel(harnessDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			changes.harness = true
+			continue
+		}
+		// The feature is the deepest feature dir containing the file, which is
+		// found by walking up to the first dir with a feature file of any
+		// language. Files in no feature dir are shared by features.
+		rel, err := filepath.Rel(featuresDir, filepath.Dir(path))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		featureDir := ""
+		for dir := rel; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			if matches, _ := filepath.Glob(filepath.Join(featuresDir, dir, "feature.*")); len(matches) > 0 {
+				featureDir = filepath.ToSlash(dir)
+				break
+			}
+		}
+		if featureDir != "" {
+			changes.features[featureDir] = true
+		} else {
+			changes.shared = true
+		}
+	}
+}
+
+func (r *Runner) isCompiledSource(path string) bool {
+	for _, ext := range compiledSourceExts[r.config.Lang] {
+		if filepath.Ext(path) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildProgram rebuilds the current program in place. Only languages that
+// compile ahead of running need this.
+func (r *Runner) rebuildProgram(ctx context.Context) error {
+	if r.program == nil {
+		// Not built yet, it will be built on next run
+		return nil
+	}
+	dirName, err := filepath.Rel(r.rootDir, r.program.Dir())
+	if err != nil {
+		return fmt.Errorf("program dir not beneath root: %w", err)
+	}
+	prepareConfig := r.config.PrepareConfig
+	prepareConfig.DirName = dirName
+	preparer := NewPreparer(prepareConfig)
+	r.log.Info("Rebuilding program", "Dir", r.program.Dir())
+	var prog sdkbuild.Program
+	switch r.config.Lang {
+	case "go":
+		prog, err = preparer.BuildGoProgram(ctx)
+	case "ts":
+		prog, err = preparer.BuildTypeScriptProgram(ctx)
+	case "cs":
+		prog, err = preparer.BuildDotNetProgram(ctx)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.program = prog
+	return nil
+}
+
+// snapshotFiles collects the state of all files beneath the given roots,
+// skipping hidden and cache entries.
+func snapshotFiles(roots []string) map[string]watchedFileState {
+	files := map[string]watchedFileState{}
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "__pycache__" || name == "node_modules") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				files[path] = watchedFileState{modTime: info.ModTime(), size: info.Size()}
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// diffSnapshots returns the sorted paths added, removed, or modified between
+// snapshots.
+func diffSnapshots(prev, curr map[string]watchedFileState) []string {
+	var changed []string
+	for path, state := range curr {
+		if prevState, ok := prev[path]; !ok || prevState != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range prev {
+		if _, ok := curr[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
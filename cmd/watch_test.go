@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchClassifiesChanges(t *testing.T) {
+	r := NewRunner(RunConfig{PrepareConfig: PrepareConfig{Lang: "go"}})
+	r.rootDir = t.TempDir()
+	featureDir := filepath.Join(r.rootDir, "features", "activity", "basic")
+	if err := os.MkdirAll(filepath.Join(featureDir, "history"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(featureDir, "feature.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Non-source change in a feature only re-runs that feature
+	changes := &watchChanges{features: map[string]bool{}}
+	r.classifyChanges(changes, []string{filepath.Join(featureDir, "history", "history.go.v1.0.0.json")})
+	if !changes.features["activity/basic"] || changes.harness || changes.rebuild {
+		t.Fatalf("unexpected changes for history file: %+v", changes)
+	}
+
+	// Source change in a feature requires a rebuild
+	changes = &watchChanges{features: map[string]bool{}}
+	r.classifyChanges(changes, []string{filepath.Join(featureDir, "feature.go")})
+	if !changes.features["activity/basic"] || !changes.rebuild {
+		t.Fatalf("unexpected changes for feature source: %+v", changes)
+	}
+
+	// Shared helper change outside any feature dir affects everything
+	changes = &watchChanges{features: map[string]bool{}}
+	r.classifyChanges(changes, []string{filepath.Join(r.rootDir, "features", "activity", "common.go")})
+	if !changes.shared || !changes.rebuild || len(changes.features) != 0 {
+		t.Fatalf("unexpected changes for shared source: %+v", changes)
+	}
+
+	// Harness change affects everything
+	changes = &watchChanges{features: map[string]bool{}}
+	r.classifyChanges(changes, []string{filepath.Join(r.rootDir, "harness", "go", "harness", "runner.go")})
+	if !changes.harness || !changes.rebuild || len(changes.features) != 0 {
+		t.Fatalf("unexpected changes for harness source: %+v", changes)
+	}
+}
+
+func TestWatchSnapshotDiff(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "feature.py")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "__pycache__"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshotFiles([]string{dir})
+	if err := os.WriteFile(file, []byte("ab"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "__pycache__", "feature.pyc"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed := diffSnapshots(before, snapshotFiles([]string{dir}))
+	if len(changed) != 1 || changed[0] != file {
+		t.Fatalf("changed = %v, want only %v", changed, file)
+	}
+}
@@ -24,10 +24,21 @@ var Feature = harness.Feature{
 	Execute:       Execute,
 	CheckHistory:  CheckHistory,
 	WorkerOptions: worker.Options{BuildID: "1.0", UseBuildIDForVersioning: true},
+	Workers: []harness.FeatureWorker{{
+		Name: "2.0",
+		Workflows: []interface{}{
+			Workflow,
+			harness.WorkflowWithOptions{Workflow: RanBy2Child, Options: workflow.RegisterOptions{Name: "RanByWf"}},
+		},
+		Activities: harness.ActivityWithOptions{
+			Activity: RanBy2Act,
+			Options:  activity.RegisterOptions{Name: "RanBy"},
+		},
+		Options:     worker.Options{BuildID: "2.0", UseBuildIDForVersioning: true},
+		ManualStart: true,
+	}},
 }
 
-var twoWorker worker.Worker
-
 func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error) {
 	if supported, err := build_id_versioning.ServerSupportsBuildIDVersioning(ctx, r); !supported || err != nil {
 		if err != nil {
@@ -47,14 +58,7 @@ func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error)
 	}
 
 	// Also start a 2.0 activity worker
-	twoWorker = worker.New(r.Client, r.RunnerConfig.TaskQueue, worker.Options{
-		BuildID:                 "2.0",
-		UseBuildIDForVersioning: true,
-	})
-	twoWorker.RegisterActivityWithOptions(RanBy2Act, activity.RegisterOptions{Name: "RanBy"})
-	twoWorker.RegisterWorkflowWithOptions(RanBy2Child, workflow.RegisterOptions{Name: "RanByWf"})
-	twoWorker.RegisterWorkflow(Workflow)
-	err = twoWorker.Start()
+	err = r.StartNamedWorker("2.0")
 	if err != nil {
 		return nil, err
 	}
@@ -158,6 +162,6 @@ func RanBy2Child(_ workflow.Context) (int, error) {
 
 func CheckHistory(ctx context.Context, r *harness.Runner, run client.WorkflowRun) error {
 	// Shut down the 2.0 worker
-	twoWorker.Stop()
+	r.StopNamedWorker("2.0")
 	return r.CheckHistoryDefault(ctx, run)
 }
@@ -21,10 +21,17 @@ var Feature = harness.Feature{
 	Execute:       Execute,
 	CheckHistory:  CheckHistory,
 	WorkerOptions: worker.Options{BuildID: "1.0", UseBuildIDForVersioning: true},
+	Workers: []harness.FeatureWorker{{
+		Name: "2.0",
+		Workflows: harness.WorkflowWithOptions{
+			Workflow: Worker2WF,
+			Options:  workflow.RegisterOptions{Name: "Workflow"},
+		},
+		Options:     worker.Options{BuildID: "2.0", UseBuildIDForVersioning: true},
+		ManualStart: true,
+	}},
 }
 
-var twoWorker worker.Worker
-
 func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error) {
 	if supported, err := build_id_versioning.ServerSupportsBuildIDVersioning(ctx, r); !supported || err != nil {
 		if err != nil {
@@ -44,12 +51,7 @@ func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error)
 	}
 
 	// Also start a 2.0 activity worker
-	twoWorker = worker.New(r.Client, r.RunnerConfig.TaskQueue, worker.Options{
-		BuildID:                 "2.0",
-		UseBuildIDForVersioning: true,
-	})
-	twoWorker.RegisterWorkflowWithOptions(Worker2WF, workflow.RegisterOptions{Name: "Workflow"})
-	err = twoWorker.Start()
+	err = r.StartNamedWorker("2.0")
 	if err != nil {
 		return nil, err
 	}
@@ -154,6 +156,6 @@ func Workflow(ctx workflow.Context, myVer string) error {
 
 func CheckHistory(ctx context.Context, r *harness.Runner, run client.WorkflowRun) error {
 	// Shut down the 2.0 worker
-	twoWorker.Stop()
+	r.StopNamedWorker("2.0")
 	return r.CheckHistoryDefault(ctx, run)
 }
@@ -12,22 +12,28 @@ import (
 	"go.temporal.io/sdk/workflow"
 )
 
-func StartWorker(ctx context.Context, r *harness.Runner,
+// Worker returns a manually started feature worker for the version that
+// registers waitForSignal as the WaitForSignal workflow.
+func Worker(name string,
 	version worker.WorkerDeploymentVersion,
 	versioningBehavior workflow.VersioningBehavior,
 	waitForSignal func(workflow.Context) (string, error),
-) worker.Worker {
-	w := worker.New(r.Client, r.TaskQueue, worker.Options{
-		DeploymentOptions: worker.DeploymentOptions{
-			UseVersioning:             true,
-			Version:                   version,
-			DefaultVersioningBehavior: versioningBehavior,
+) harness.FeatureWorker {
+	return harness.FeatureWorker{
+		Name: name,
+		Workflows: harness.WorkflowWithOptions{
+			Workflow: waitForSignal,
+			Options:  workflow.RegisterOptions{Name: "WaitForSignal"},
 		},
-	})
-	w.RegisterWorkflowWithOptions(waitForSignal, workflow.RegisterOptions{
-		Name: "WaitForSignal",
-	})
-	return w
+		Options: worker.Options{
+			DeploymentOptions: worker.DeploymentOptions{
+				UseVersioning:             true,
+				Version:                   version,
+				DefaultVersioningBehavior: versioningBehavior,
+			},
+		},
+		ManualStart: true,
+	}
 }
 
 func WaitForDeploymentVersion(
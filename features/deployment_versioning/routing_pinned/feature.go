@@ -52,19 +52,19 @@ var Feature = harness.Feature{
 			Version:       v1,
 		},
 	},
+	Workers: []harness.FeatureWorker{
+		deployment_versioning.Worker("v2", v2, workflow.VersioningBehaviorAutoUpgrade, WaitForSignalTwo),
+	},
 	CheckHistory:    CheckHistory,
 	ExpectRunResult: "prefix_v1",
 }
-var worker2 worker.Worker
 
 func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error) {
 	if supported := deployment_versioning.ServerSupportsDeployments(ctx, r); !supported {
 		return nil, r.Skip(fmt.Sprintf("server does not support deployment versioning"))
 	}
 
-	worker2 = deployment_versioning.StartWorker(ctx, r, v2,
-		workflow.VersioningBehaviorAutoUpgrade, WaitForSignalTwo)
-	if err := worker2.Start(); err != nil {
+	if err := r.StartNamedWorker("v2"); err != nil {
 		return nil, err
 	}
 
@@ -99,6 +99,6 @@ func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error)
 
 func CheckHistory(ctx context.Context, r *harness.Runner, run client.WorkflowRun) error {
 	// Shut down the 2.0 worker
-	worker2.Stop()
+	r.StopNamedWorker("v2")
 	return r.CheckHistoryDefault(ctx, run)
 }
@@ -2,13 +2,12 @@ package sync_success
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/nexus-rpc/sdk-go/nexus"
 	"github.com/temporalio/features/harness/go/harness"
+	"github.com/temporalio/features/harness/go/history"
 	enumspb "go.temporal.io/api/enums/v1"
-	historypb "go.temporal.io/api/history/v1"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/workflow"
 )
@@ -54,26 +53,15 @@ var Feature = harness.Feature{
 	CheckHistory: func(ctx context.Context, runner *harness.Runner, run client.WorkflowRun) error {
 		// Sync Nexus operations should transition directly from Scheduled to Completed with
 		// no Started event in between.
-		hasEvent := func(t enumspb.EventType) (bool, error) {
-			hist := runner.Client.GetWorkflowHistory(ctx, run.GetID(), run.GetRunID(), false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
-			ev, err := harness.FindEvent(hist, func(ev *historypb.HistoryEvent) bool { return ev.EventType == t })
-			return ev != nil, err
-		}
-		if ok, err := hasEvent(enumspb.EVENT_TYPE_NEXUS_OPERATION_SCHEDULED); err != nil {
-			return err
-		} else if !ok {
-			return fmt.Errorf("did not find NexusOperationScheduled event in history")
-		}
-		if ok, err := hasEvent(enumspb.EVENT_TYPE_NEXUS_OPERATION_COMPLETED); err != nil {
+		exp, err := runner.ExpectHistory(ctx, run)
+		if err != nil {
 			return err
-		} else if !ok {
-			return fmt.Errorf("did not find NexusOperationCompleted event in history")
-		}
-		if ok, err := hasEvent(enumspb.EVENT_TYPE_NEXUS_OPERATION_STARTED); err != nil {
+		} else if err := exp.InOrder(
+			history.Event(enumspb.EVENT_TYPE_NEXUS_OPERATION_SCHEDULED),
+			history.Event(enumspb.EVENT_TYPE_NEXUS_OPERATION_COMPLETED),
+		); err != nil {
 			return err
-		} else if ok {
-			return fmt.Errorf("unexpected NexusOperationStarted event for sync operation")
 		}
-		return nil
+		return exp.None(history.Event(enumspb.EVENT_TYPE_NEXUS_OPERATION_STARTED))
 	},
 }
@@ -6,7 +6,6 @@ import (
 
 	"github.com/temporalio/features/harness/go/harness"
 	"github.com/temporalio/features/harness/go/history"
-	enumspb "go.temporal.io/api/enums/v1"
 )
 
 func RequireNoUpdateRejectedEvents(ctx context.Context, runner *harness.Runner) {
@@ -23,11 +22,7 @@ func RequireNoUpdateRejectedEvents(ctx context.Context, runner *harness.Runner)
 	histories, err := fetcher.Fetch(ctx)
 	runner.Require.NoError(err)
 	for _, hist := range histories {
-		for _, ev := range hist.GetEvents() {
-			if ev.GetEventType() == enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_UPDATE_REJECTED {
-				runner.Require.FailNow("found a workflow update rejected event")
-			}
-		}
+		runner.Require.NoError(history.Expect(hist).None(history.UpdateRejected()))
 	}
 	runner.Log.Debug("No histories contained update rejected events", "history-count", len(histories))
 }
@@ -13,7 +13,6 @@ import (
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 
-	"github.com/google/uuid"
 	"github.com/temporalio/features/harness/go/harness"
 )
 
@@ -31,13 +30,20 @@ var Feature = harness.Feature{
 	},
 	Execute:      Execute,
 	CheckHistory: func(context.Context, *harness.Runner, client.WorkflowRun) error { return nil },
+	Teardown:     Teardown,
+}
+
+// workflowID returns the ID of the i-th workflow, unique per run since the task
+// queue is.
+func workflowID(r *harness.Runner, i int) string {
+	return fmt.Sprintf("%s-%s-%d", r.Feature.Dir, r.TaskQueue, i)
 }
 
 func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error) {
 	runs := make([]client.WorkflowRun, 0, workflowCount)
 	for i := 0; i < workflowCount; i++ {
 		run, err := r.Client.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
-			ID:                       fmt.Sprintf("%s-%s", r.Feature.Dir, uuid.NewString()),
+			ID:                       workflowID(r, i),
 			TaskQueue:                r.TaskQueue,
 			WorkflowExecutionTimeout: 1 * time.Minute,
 			WorkflowTaskTimeout:      5 * time.Second,
@@ -48,12 +54,6 @@ func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error)
 		runs = append(runs, run)
 	}
 
-	defer func() {
-		for _, run := range runs {
-			_ = r.Client.TerminateWorkflow(context.Background(), run.GetID(), run.GetRunID(), "feature cleanup")
-		}
-	}()
-
 	for _, run := range runs {
 		if _, err := r.WaitForActivityTaskScheduled(ctx, run, 10*time.Second); err != nil {
 			return nil, err
@@ -82,6 +82,13 @@ func Execute(ctx context.Context, r *harness.Runner) (client.WorkflowRun, error)
 	return nil, nil
 }
 
+// Teardown terminates the workflows, which run until terminated.
+func Teardown(ctx context.Context, r *harness.Runner) {
+	for i := 0; i < workflowCount; i++ {
+		_ = r.Client.TerminateWorkflow(ctx, workflowID(r, i), "", "feature cleanup")
+	}
+}
+
 func Workflow(ctx workflow.Context) error {
 	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
 		ScheduleToCloseTimeout: 10 * time.Second,
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/temporalio/features/harness/go/harness"
+	"github.com/temporalio/features/harness/go/history"
 	"github.com/urfave/cli/v2"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/log"
@@ -22,6 +23,10 @@ const (
 	FeaturePassed  = "PASSED"
 	FeatureFailed  = "FAILED"
 	FeatureSkipped = "SKIPPED"
+
+	// CrossLanguageReplaySummarySuffix is appended to a feature's summary name for
+	// the summary entry of its cross-language replay.
+	CrossLanguageReplaySummarySuffix = "/cross-language-replay"
 )
 
 func runCmd() *cli.Command {
@@ -49,7 +54,11 @@ type Run struct {
 func (r *Run) ToArgs() []string {
 	ret := make([]string, len(r.Features))
 	for i, feature := range r.Features {
-		ret[i] = feature.Dir + ":" + feature.TaskQueue
+		ret[i] = feature.Dir
+		if feature.CaseName != "" {
+			ret[i] += history.VariantCaseSeparator + feature.CaseName
+		}
+		ret[i] += ":" + feature.TaskQueue
 		if feature.NexusEndpoint != "" {
 			ret[i] += ":" + feature.NexusEndpoint
 		}
@@ -74,7 +83,8 @@ func parseRunFeature(arg string) (RunFeature, error) {
 	if len(pieces) < 2 {
 		return RunFeature{}, fmt.Errorf("missing task queue")
 	}
-	feature := RunFeature{Dir: pieces[0], TaskQueue: pieces[1]}
+	feature := RunFeature{TaskQueue: pieces[1]}
+	feature.Dir, feature.CaseName, _ = strings.Cut(pieces[0], history.VariantCaseSeparator)
 	if len(pieces) == 3 {
 		feature.NexusEndpoint = pieces[2]
 	}
@@ -90,13 +100,36 @@ type RunFeature struct {
 	NexusEndpoint string
 	Config        RunFeatureConfig
 	VariantName   string
+	// CaseName is the case of a table-driven Go feature to run, if any.
+	CaseName string
 }
 
+// SummaryName is the name of the feature in the summary, which is the
+// directory followed by the variant name after a "#" and the case name after a
+// history.VariantCaseSeparator, if any. Neither name may contain the other's
+// separator, so the name is unambiguous.
 func (r RunFeature) SummaryName() string {
-	if r.VariantName == "" {
-		return r.Dir
+	name := r.Dir
+	if r.VariantName != "" {
+		name += "#" + r.VariantName
+	}
+	if r.CaseName != "" {
+		name += history.VariantCaseSeparator + r.CaseName
 	}
-	return r.Dir + "#" + r.VariantName
+	return name
+}
+
+// CrossLanguageReplaySummaryName is the name in the summary of the replay of the
+// feature's stored histories of other languages, which is reported apart from
+// the feature itself.
+func (r RunFeature) CrossLanguageReplaySummaryName() string {
+	return r.SummaryName() + CrossLanguageReplaySummarySuffix
+}
+
+// HistoryVariant is the variant history of the feature is stored as, which is
+// the variant and case names, if any, joined by history.JoinVariant.
+func (r RunFeature) HistoryVariant() string {
+	return history.JoinVariant(r.VariantName, r.CaseName)
 }
 
 // RunFeatureConfig is config from config.json.
@@ -106,6 +139,10 @@ type RunFeatureConfig struct {
 	ExpectUnauthedProxyCount int                `json:"expectUnauthedProxyCount"`
 	ExpectAuthedProxyCount   int                `json:"expectAuthedProxyCount"`
 	RunVariants              []RunVariantConfig `json:"runVariants"`
+	// Applied when comparing the feature's history against stored histories
+	HistoryCompare history.CompareConfig `json:"historyCompare"`
+	// Whether harnesses also replay stored histories of other languages
+	CrossLanguageReplay bool `json:"crossLanguageReplay"`
 }
 
 // RunVariantConfig describes one named way to run a feature. Variants are
@@ -137,6 +174,12 @@ type RunConfig struct {
 	SummaryURI     string
 	HTTPProxyURL   string
 	TLSServerName  string
+	// FailFast stops running features after the first one fails. Features
+	// already run are still reported in the summary.
+	FailFast bool
+	// ReplayOnly replays each feature's stored histories instead of running it.
+	// No server is used.
+	ReplayOnly bool
 }
 
 func (r *RunConfig) flags() []cli.Flag {
@@ -181,6 +224,16 @@ func (r *RunConfig) flags() []cli.Flag {
 			Usage:       "TLS server name to use for verification (optional)",
 			Destination: &r.TLSServerName,
 		},
+		&cli.BoolFlag{
+			Name:        "fail-fast",
+			Usage:       "Stop running features after the first failure",
+			Destination: &r.FailFast,
+		},
+		&cli.BoolFlag{
+			Name:        "replay-only",
+			Usage:       "Only replay stored histories of the features without a server",
+			Destination: &r.ReplayOnly,
+		},
 	}
 }
 
@@ -246,6 +299,19 @@ func (r *Runner) Run(ctx context.Context, run *Run) error {
 		if feature == nil {
 			return fmt.Errorf("feature %v not found, did you add it to features.go?", runFeature.Dir)
 		}
+		// Replay covers the stored histories of all cases
+		if runFeature.CaseName != "" {
+			var err error
+			if feature, err = feature.WithCase(runFeature.CaseName); err != nil {
+				return err
+			}
+		} else if len(feature.Cases) > 0 && !r.config.ReplayOnly {
+			return fmt.Errorf("feature %v has cases, one must be given as %v%v<case>",
+				runFeature.Dir, runFeature.Dir, history.VariantCaseSeparator)
+		}
+		// Set if the feature opted in to cross-language replay, which is run and
+		// reported apart from the feature itself
+		var crossLanguageConfig *harness.RunnerConfig
 		err := func() error {
 			sumEntry := struct {
 				Name    string `json:"name"`
@@ -267,10 +333,11 @@ func (r *Runner) Run(ctx context.Context, run *Run) error {
 				return nil
 			}
 
-			if feature.StartWorkflowOptionsMutator == nil {
-				feature.StartWorkflowOptionsMutator = func(opts *client.StartWorkflowOptions) {}
+			// External runs are not given the feature config, so always load it
+			var featureConfig RunFeatureConfig
+			if err := featureConfig.LoadFromDir(feature.AbsDir); err != nil {
+				return fmt.Errorf("failed reading config for %v: %w", feature.Dir, err)
 			}
-
 			runnerConfig := harness.RunnerConfig{
 				ServerHostPort: r.config.Server,
 				Namespace:      r.config.Namespace,
@@ -282,12 +349,21 @@ func (r *Runner) Run(ctx context.Context, run *Run) error {
 				Log:            r.log,
 				HTTPProxyURL:   r.config.HTTPProxyURL,
 				TLSServerName:  r.config.TLSServerName,
+				HistoryCompare: featureConfig.HistoryCompare,
+			}
+			if featureConfig.CrossLanguageReplay {
+				crossLanguageConfig = &runnerConfig
 			}
 			if runFeature.VariantName != "" {
 				r.log.Info("Running feature variant", "Feature", feature.Dir, "Variant", runFeature.VariantName)
 			}
 
-			err := r.runFeature(ctx, runnerConfig, feature)
+			var err error
+			if r.config.ReplayOnly {
+				err = r.replayFeature(ctx, runnerConfig, feature)
+			} else {
+				err = r.runFeature(ctx, runnerConfig, feature)
+			}
 
 			if skip, reason := harness.IsSkipError(err); skip {
 				sumEntry.Outcome = FeatureSkipped
@@ -307,6 +383,17 @@ func (r *Runner) Run(ctx context.Context, run *Run) error {
 		if err != nil {
 			return err
 		}
+		if crossLanguageConfig != nil {
+			if err := r.replayCrossLanguage(ctx, *crossLanguageConfig, feature, runFeature, summary); err != nil {
+				failureCount++
+				r.log.Error("Feature failed cross-language replay", "Feature", feature.Dir, "error", err)
+				failureSummary += fmt.Sprintf("Feature %v failed cross-language replay: %v\n", feature.Dir, err)
+			}
+		}
+		if failureCount > 0 && r.config.FailFast {
+			r.log.Warn("Stopping after first failed feature", "Feature", runFeature.SummaryName())
+			break
+		}
 	}
 	if failureCount > 0 {
 		return fmt.Errorf("%v failure(s) reported:\n%s", failureCount, failureSummary)
@@ -315,13 +402,57 @@ func (r *Runner) Run(ctx context.Context, run *Run) error {
 	return nil
 }
 
+// replayCrossLanguage replays the stored histories of other languages of the
+// feature, whatever the outcome of the feature itself, and writes the result
+// to the summary as a separate entry.
+func (r *Runner) replayCrossLanguage(
+	ctx context.Context,
+	config harness.RunnerConfig,
+	feature *harness.PreparedFeature,
+	runFeature RunFeature,
+	summary io.Writer,
+) error {
+	err := harness.NewReplayRunner(config, feature).ReplayCrossLanguageHistories(ctx)
+	sumEntry := struct {
+		Name    string `json:"name"`
+		Outcome string `json:"outcome"`
+		Message string `json:"message"`
+	}{
+		Name:    runFeature.CrossLanguageReplaySummaryName(),
+		Outcome: FeaturePassed,
+	}
+	if err != nil {
+		sumEntry.Outcome = FeatureFailed
+		sumEntry.Message = err.Error()
+	}
+	bytes, _ := json.Marshal(sumEntry)
+	fmt.Fprintln(summary, string(bytes))
+	return err
+}
+
+// replayFeature replays the stored histories of the feature.
+func (r *Runner) replayFeature(
+	ctx context.Context,
+	config harness.RunnerConfig,
+	feature *harness.PreparedFeature,
+) error {
+	return harness.NewReplayRunner(config, feature).ReplayStoredHistories(ctx)
+}
+
 func (r *Runner) runFeature(
 	ctx context.Context,
 	config harness.RunnerConfig,
 	feature *harness.PreparedFeature,
 ) error {
+	// Run a copy since the runner sets per-run options on the feature and the
+	// same feature may be run concurrently
+	featureCopy := *feature
+	if featureCopy.StartWorkflowOptionsMutator == nil {
+		featureCopy.StartWorkflowOptionsMutator = func(opts *client.StartWorkflowOptions) {}
+	}
+
 	// Create runner
-	runner, err := harness.NewRunner(config, feature)
+	runner, err := harness.NewRunner(ctx, config, &featureCopy)
 	if err != nil {
 		return fmt.Errorf("failed starting runner: %w", err)
 	}
@@ -358,7 +489,28 @@ func (r *RunFeatureConfig) Validate() error {
 		if _, ok := seen[variant.Name]; ok {
 			return fmt.Errorf("duplicate run variant name %q", variant.Name)
 		}
+		// The case name follows the separator in summary names
+		if strings.Contains(variant.Name, history.VariantCaseSeparator) {
+			return fmt.Errorf("run variant name %q cannot contain %q", variant.Name, history.VariantCaseSeparator)
+		}
 		seen[variant.Name] = struct{}{}
 	}
+	if err := r.HistoryCompare.Validate(); err != nil {
+		return fmt.Errorf("invalid historyCompare: %w", err)
+	}
+	// Only the Go harness replays stored histories, those of other languages
+	// only with cross-language replay, so a replay policy would skip them
+	for lang, policies := range r.HistoryCompare.Versions {
+		if lang == "go" || r.CrossLanguageReplay {
+			continue
+		}
+		for _, policy := range policies {
+			if policy.Check == history.VersionCheckReplay {
+				return fmt.Errorf("invalid historyCompare: %v version policy for %q cannot be %q since only Go "+
+					"replays stored histories, and other languages' only with crossLanguageReplay",
+					lang, policy.Stored, history.VersionCheckReplay)
+			}
+		}
+	}
 	return nil
 }
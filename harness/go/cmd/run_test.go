@@ -3,12 +3,15 @@ package cmd
 import (
 	"reflect"
 	"testing"
+
+	"github.com/temporalio/features/harness/go/history"
 )
 
 func TestRunToArgsAndFromArgsRoundTrip(t *testing.T) {
 	run := Run{Features: []RunFeature{
 		{Dir: "activity/basic", TaskQueue: "tq-basic"},
 		{Dir: "nexus/sync_success", TaskQueue: "tq-nexus", NexusEndpoint: "endpoint-name"},
+		{Dir: "activity/cancellation", TaskQueue: "tq-case", CaseName: "abandon"},
 	}}
 
 	args := run.ToArgs()
@@ -60,6 +63,34 @@ func TestRunFeatureConfigValidateRunVariants(t *testing.T) {
 				{Name: "enabled/invalid"},
 			}},
 		},
+		{
+			name: "case separator",
+			config: RunFeatureConfig{RunVariants: []RunVariantConfig{
+				{Name: "enabled+abandon"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "go replay policy",
+			config: RunFeatureConfig{HistoryCompare: history.CompareConfig{Versions: map[string][]history.VersionPolicy{
+				"go": {{Stored: "<1.20.0", Check: history.VersionCheckReplay}},
+			}}},
+		},
+		{
+			name: "replay policy of language that does not replay",
+			config: RunFeatureConfig{HistoryCompare: history.CompareConfig{Versions: map[string][]history.VersionPolicy{
+				"java": {{Stored: "<1.20.0", Check: history.VersionCheckReplay}},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "replay policy of language replayed cross-language",
+			config: RunFeatureConfig{CrossLanguageReplay: true, HistoryCompare: history.CompareConfig{
+				Versions: map[string][]history.VersionPolicy{
+					"java": {{Stored: "<1.20.0", Check: history.VersionCheckReplay}},
+				},
+			}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -73,3 +104,23 @@ func TestRunFeatureConfigValidateRunVariants(t *testing.T) {
 		})
 	}
 }
+
+func TestRunFeatureSummaryNameAndHistoryVariant(t *testing.T) {
+	tests := []struct {
+		feature        RunFeature
+		summaryName    string
+		historyVariant string
+	}{
+		{RunFeature{Dir: "activity/basic"}, "activity/basic", ""},
+		{RunFeature{Dir: "activity/basic", VariantName: "enabled"}, "activity/basic#enabled", "enabled"},
+		{RunFeature{Dir: "activity/basic", CaseName: "abandon"}, "activity/basic+abandon", "abandon"},
+		{RunFeature{Dir: "activity/basic", VariantName: "enabled", CaseName: "abandon"}, "activity/basic#enabled+abandon", "enabled+abandon"},
+	}
+	for _, tt := range tests {
+		if got := tt.feature.SummaryName(); got != tt.summaryName {
+			t.Fatalf("SummaryName() = %v, want %v", got, tt.summaryName)
+		} else if got := tt.feature.HistoryVariant(); got != tt.historyVariant {
+			t.Fatalf("HistoryVariant() = %v, want %v", got, tt.historyVariant)
+		}
+	}
+}
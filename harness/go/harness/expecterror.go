@@ -0,0 +1,211 @@
+package harness
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+)
+
+// ErrorKind is the kind of a workflow error, which is the SDK error type.
+type ErrorKind string
+
+const (
+	// ErrorKindApplication is a *temporal.ApplicationError.
+	ErrorKindApplication ErrorKind = "application"
+	// ErrorKindActivity is a *temporal.ActivityError.
+	ErrorKindActivity ErrorKind = "activity"
+	// ErrorKindChildWorkflow is a *temporal.ChildWorkflowExecutionError.
+	ErrorKindChildWorkflow ErrorKind = "childWorkflow"
+	// ErrorKindNexusOperation is a *temporal.NexusOperationError.
+	ErrorKindNexusOperation ErrorKind = "nexusOperation"
+	// ErrorKindCanceled is a *temporal.CanceledError.
+	ErrorKindCanceled ErrorKind = "canceled"
+	// ErrorKindTimeout is a *temporal.TimeoutError.
+	ErrorKindTimeout ErrorKind = "timeout"
+	// ErrorKindTerminated is a *temporal.TerminatedError.
+	ErrorKindTerminated ErrorKind = "terminated"
+	// ErrorKindPanic is a *temporal.PanicError.
+	ErrorKindPanic ErrorKind = "panic"
+	// ErrorKindServer is a *temporal.ServerError.
+	ErrorKindServer ErrorKind = "server"
+)
+
+// ExpectedError is a declarative expectation of a workflow failure, used by
+// CheckResultDefault via Feature.ExpectError. Fields left empty are not
+// checked.
+type ExpectedError struct {
+	// Kind of the error.
+	Kind ErrorKind
+
+	// Type of an application error.
+	Type string
+
+	// Regular expression the error's own message must match, not including the
+	// message of its cause. It is not anchored. For activity and child workflow
+	// errors, the message includes details like the activity or workflow type.
+	Message string
+
+	// If true, the error must be a non-retryable application error.
+	NonRetryable bool
+
+	// Timeout type of a timeout error.
+	TimeoutType enums.TimeoutType
+
+	// Details of an application error or canceled error, or last heartbeat
+	// details of a timeout error. They are decoded with the feature's data
+	// converter into values of the same types and must equal these.
+	Details []interface{}
+
+	// Expectation of the error's direct cause, which can have a cause itself to
+	// match further down the cause chain.
+	Cause *ExpectedError
+}
+
+// CheckError checks the error against the expectation. A
+// *temporal.WorkflowExecutionError as returned by WorkflowRun.Get is unwrapped
+// first, so the expectation is of the workflow's failure.
+func (r *Runner) CheckError(err error, expected *ExpectedError) error {
+	var workflowErr *temporal.WorkflowExecutionError
+	if errors.As(err, &workflowErr) {
+		err = workflowErr.Unwrap()
+	}
+	if err == nil {
+		return fmt.Errorf("expected error, got success")
+	}
+	return r.checkError(err, expected)
+}
+
+func (r *Runner) checkError(err error, expected *ExpectedError) error {
+	if err == nil {
+		return fmt.Errorf("expected error, got no error")
+	}
+	if expected.Kind != "" && errorKind(err) != expected.Kind {
+		return fmt.Errorf("expected %v error, got %v: %w", expected.Kind, describeErrorKind(err), err)
+	}
+	appErr, isAppErr := err.(*temporal.ApplicationError)
+	if expected.Type != "" && (!isAppErr || appErr.Type() != expected.Type) {
+		return fmt.Errorf("expected application error type %v, got %v: %w", expected.Type, describeErrorKind(err), err)
+	} else if expected.NonRetryable && (!isAppErr || !appErr.NonRetryable()) {
+		return fmt.Errorf("expected non-retryable application error, got %v: %w", describeErrorKind(err), err)
+	}
+	if expected.Message != "" {
+		re, reErr := regexp.Compile(expected.Message)
+		if reErr != nil {
+			return fmt.Errorf("invalid expected error message pattern %q: %w", expected.Message, reErr)
+		} else if message := errorMessage(err); !re.MatchString(message) {
+			return fmt.Errorf("expected error message matching %q, got %q: %w", expected.Message, message, err)
+		}
+	}
+	if expected.TimeoutType != enums.TIMEOUT_TYPE_UNSPECIFIED {
+		if timeoutErr, ok := err.(*temporal.TimeoutError); !ok || timeoutErr.TimeoutType() != expected.TimeoutType {
+			return fmt.Errorf("expected %v timeout error, got %v: %w", expected.TimeoutType, describeErrorKind(err), err)
+		}
+	}
+	if len(expected.Details) > 0 {
+		if detailsErr := r.checkErrorDetails(err, expected.Details); detailsErr != nil {
+			return detailsErr
+		}
+	}
+	if expected.Cause != nil {
+		if causeErr := r.checkError(errors.Unwrap(err), expected.Cause); causeErr != nil {
+			return fmt.Errorf("cause of %v: %w", describeErrorKind(err), causeErr)
+		}
+	}
+	return nil
+}
+
+// checkErrorDetails decodes the details of the error into values of the same
+// types as the expected ones and compares them.
+func (r *Runner) checkErrorDetails(err error, expected []interface{}) error {
+	ptrs := make([]interface{}, len(expected))
+	for i, value := range expected {
+		// An untyped nil has no type to decode into, so the detail is decoded
+		// as is and must be nil too
+		if value == nil {
+			ptrs[i] = new(interface{})
+		} else {
+			ptrs[i] = reflect.New(reflect.TypeOf(value)).Interface()
+		}
+	}
+	var decodeErr error
+	switch err := err.(type) {
+	case *temporal.ApplicationError:
+		decodeErr = err.Details(ptrs...)
+	case *temporal.CanceledError:
+		decodeErr = err.Details(ptrs...)
+	case *temporal.TimeoutError:
+		decodeErr = err.LastHeartbeatDetails(ptrs...)
+	default:
+		return fmt.Errorf("expected error details, but %v has none", describeErrorKind(err))
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("failed decoding error details: %w", decodeErr)
+	}
+	for i, value := range expected {
+		actual := reflect.ValueOf(ptrs[i]).Elem().Interface()
+		if err := r.CheckAssertion(r.SoftAssert.Equal(value, actual)); err != nil {
+			return fmt.Errorf("error detail %v mismatch: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func errorKind(err error) ErrorKind {
+	switch err.(type) {
+	case *temporal.ApplicationError:
+		return ErrorKindApplication
+	case *temporal.ActivityError:
+		return ErrorKindActivity
+	case *temporal.ChildWorkflowExecutionError:
+		return ErrorKindChildWorkflow
+	case *temporal.NexusOperationError:
+		return ErrorKindNexusOperation
+	case *temporal.CanceledError:
+		return ErrorKindCanceled
+	case *temporal.TimeoutError:
+		return ErrorKindTimeout
+	case *temporal.TerminatedError:
+		return ErrorKindTerminated
+	case *temporal.PanicError:
+		return ErrorKindPanic
+	case *temporal.ServerError:
+		return ErrorKindServer
+	}
+	return ""
+}
+
+// describeErrorKind returns the kind of the error for messages, or its Go type
+// if it is not of a known kind.
+func describeErrorKind(err error) string {
+	if kind := errorKind(err); kind != "" {
+		return string(kind) + " error"
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// errorMessage returns the message of the error without its cause's. Errors
+// without an accessor for it, such as activity and child workflow errors, have
+// their cause cut from their full string, which keeps details like the
+// activity or workflow type.
+func errorMessage(err error) string {
+	switch err := err.(type) {
+	case *temporal.ApplicationError:
+		return err.Message()
+	case *temporal.TimeoutError:
+		return err.Message()
+	case *temporal.ServerError:
+		return err.Message()
+	case *temporal.NexusOperationError:
+		return err.Message
+	}
+	message := err.Error()
+	if cause := errors.Unwrap(err); cause != nil {
+		message = strings.TrimSuffix(message, ": "+cause.Error())
+	}
+	return message
+}
@@ -0,0 +1,257 @@
+package harness
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/failure/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+// failureError converts the failure to an error as the SDK does for errors
+// received from the server, which is the only way to build some kinds.
+func failureError(f *failure.Failure) error {
+	return temporal.GetDefaultFailureConverter().FailureToError(f)
+}
+
+func applicationFailure(message, errType string, details ...interface{}) *failure.Failure {
+	payloads, err := converter.GetDefaultDataConverter().ToPayloads(details...)
+	if err != nil {
+		panic(err)
+	}
+	return &failure.Failure{
+		Message: message,
+		FailureInfo: &failure.Failure_ApplicationFailureInfo{ApplicationFailureInfo: &failure.ApplicationFailureInfo{
+			Type:    errType,
+			Details: payloads,
+		}},
+	}
+}
+
+func activityFailure(cause *failure.Failure) *failure.Failure {
+	return &failure.Failure{
+		Message: "activity error",
+		Cause:   cause,
+		FailureInfo: &failure.Failure_ActivityFailureInfo{ActivityFailureInfo: &failure.ActivityFailureInfo{
+			ActivityType: &common.ActivityType{Name: "MyActivity"},
+		}},
+	}
+}
+
+func childWorkflowFailure(cause *failure.Failure) *failure.Failure {
+	return &failure.Failure{
+		Message: "child workflow execution error",
+		Cause:   cause,
+		FailureInfo: &failure.Failure_ChildWorkflowExecutionFailureInfo{
+			ChildWorkflowExecutionFailureInfo: &failure.ChildWorkflowExecutionFailureInfo{
+				WorkflowType: &common.WorkflowType{Name: "MyChild"},
+			},
+		},
+	}
+}
+
+var terminatedFailure = &failure.Failure{
+	Message:     "terminated",
+	FailureInfo: &failure.Failure_TerminatedFailureInfo{TerminatedFailureInfo: &failure.TerminatedFailureInfo{}},
+}
+
+func TestErrorKind(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		kind ErrorKind
+	}{
+		{temporal.NewApplicationError("boom", "MyError"), ErrorKindApplication},
+		{failureError(activityFailure(applicationFailure("boom", "MyError"))), ErrorKindActivity},
+		{failureError(childWorkflowFailure(terminatedFailure)), ErrorKindChildWorkflow},
+		{temporal.NewCanceledError(), ErrorKindCanceled},
+		{temporal.NewTimeoutError(enums.TIMEOUT_TYPE_START_TO_CLOSE, nil), ErrorKindTimeout},
+		{failureError(terminatedFailure), ErrorKindTerminated},
+		{errors.New("boom"), ""},
+		// Wrapped errors are not unwrapped
+		{fmt.Errorf("wrapped: %w", temporal.NewCanceledError()), ""},
+	} {
+		assert.Equal(t, tt.kind, errorKind(tt.err), "error %v", tt.err)
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	r := &Runner{}
+	r.SoftAssert = assert.New(assertTestingFunc(func(format string, args ...interface{}) {
+		r.LastAssertErr = fmt.Errorf(format, args...)
+	}))
+	activityErr := failureError(activityFailure(applicationFailure("boom happened", "MyError", "detail", 5)))
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected *ExpectedError
+		// Substring of the check failure, empty if the check should pass
+		failure string
+	}{
+		{
+			name:     "success",
+			expected: &ExpectedError{},
+			failure:  "expected error, got success",
+		},
+		{
+			name:     "kind",
+			err:      temporal.NewApplicationError("boom", "MyError"),
+			expected: &ExpectedError{Kind: ErrorKindApplication},
+		},
+		{
+			name:     "kind mismatch",
+			err:      temporal.NewApplicationError("boom", "MyError"),
+			expected: &ExpectedError{Kind: ErrorKindActivity},
+			failure:  "expected activity error, got application error",
+		},
+		{
+			name:     "type",
+			err:      temporal.NewApplicationError("boom", "MyError"),
+			expected: &ExpectedError{Type: "MyError"},
+		},
+		{
+			name:     "type mismatch",
+			err:      temporal.NewApplicationError("boom", "MyError"),
+			expected: &ExpectedError{Type: "OtherError"},
+			failure:  "expected application error type OtherError",
+		},
+		{
+			name:     "type of other kind",
+			err:      temporal.NewCanceledError(),
+			expected: &ExpectedError{Type: "MyError"},
+			failure:  "expected application error type MyError, got canceled error",
+		},
+		{
+			name:     "non-retryable",
+			err:      temporal.NewNonRetryableApplicationError("boom", "MyError", nil),
+			expected: &ExpectedError{NonRetryable: true},
+		},
+		{
+			name:     "non-retryable mismatch",
+			err:      temporal.NewApplicationError("boom", "MyError"),
+			expected: &ExpectedError{NonRetryable: true},
+			failure:  "expected non-retryable application error",
+		},
+		{
+			name:     "message",
+			err:      temporal.NewApplicationError("boom happened", "MyError"),
+			expected: &ExpectedError{Message: "^boom"},
+		},
+		{
+			name:     "message mismatch",
+			err:      temporal.NewApplicationError("boom happened", "MyError"),
+			expected: &ExpectedError{Message: "^happened"},
+			failure:  `expected error message matching "^happened", got "boom happened"`,
+		},
+		{
+			name:     "invalid message pattern",
+			err:      temporal.NewApplicationError("boom", "MyError"),
+			expected: &ExpectedError{Message: "("},
+			failure:  "invalid expected error message pattern",
+		},
+		{
+			name:     "activity message without cause",
+			err:      activityErr,
+			expected: &ExpectedError{Message: "^activity error .*MyActivity.*\\)$"},
+		},
+		{
+			name:     "activity message does not match cause",
+			err:      activityErr,
+			expected: &ExpectedError{Message: "boom"},
+			failure:  "expected error message matching \"boom\"",
+		},
+		{
+			name:     "child workflow message without cause",
+			err:      failureError(childWorkflowFailure(terminatedFailure)),
+			expected: &ExpectedError{Message: "MyChild.*\\)$"},
+		},
+		{
+			name:     "canceled message",
+			err:      temporal.NewCanceledError(),
+			expected: &ExpectedError{Message: "^canceled$"},
+		},
+		{
+			name:     "terminated message",
+			err:      failureError(terminatedFailure),
+			expected: &ExpectedError{Message: "^terminated$"},
+		},
+		{
+			name:     "timeout type",
+			err:      temporal.NewTimeoutError(enums.TIMEOUT_TYPE_HEARTBEAT, nil),
+			expected: &ExpectedError{TimeoutType: enums.TIMEOUT_TYPE_HEARTBEAT},
+		},
+		{
+			name:     "timeout type mismatch",
+			err:      temporal.NewTimeoutError(enums.TIMEOUT_TYPE_HEARTBEAT, nil),
+			expected: &ExpectedError{TimeoutType: enums.TIMEOUT_TYPE_START_TO_CLOSE},
+			failure:  "expected StartToClose timeout error",
+		},
+		{
+			name:     "details",
+			err:      failureError(applicationFailure("boom", "MyError", "detail", 5)),
+			expected: &ExpectedError{Details: []interface{}{"detail", 5}},
+		},
+		{
+			name:     "details mismatch",
+			err:      failureError(applicationFailure("boom", "MyError", "detail", 5)),
+			expected: &ExpectedError{Details: []interface{}{"detail", 6}},
+			failure:  "error detail 1 mismatch",
+		},
+		{
+			name:     "heartbeat details",
+			err:      temporal.NewTimeoutError(enums.TIMEOUT_TYPE_HEARTBEAT, nil, "progress"),
+			expected: &ExpectedError{Details: []interface{}{"progress"}},
+		},
+		{
+			name:     "details of kind without any",
+			err:      failureError(terminatedFailure),
+			expected: &ExpectedError{Details: []interface{}{"detail"}},
+			failure:  "expected error details, but terminated error has none",
+		},
+		{
+			name:     "nil detail",
+			err:      failureError(applicationFailure("boom", "MyError", nil)),
+			expected: &ExpectedError{Details: []interface{}{nil}},
+		},
+		{
+			name:     "nil detail mismatch",
+			err:      failureError(applicationFailure("boom", "MyError", "detail")),
+			expected: &ExpectedError{Details: []interface{}{nil}},
+			failure:  "error detail 0 mismatch",
+		},
+		{
+			name: "cause chain",
+			err:  activityErr,
+			expected: &ExpectedError{
+				Kind:  ErrorKindActivity,
+				Cause: &ExpectedError{Kind: ErrorKindApplication, Type: "MyError", Details: []interface{}{"detail", 5}},
+			},
+		},
+		{
+			name:     "cause mismatch",
+			err:      failureError(childWorkflowFailure(terminatedFailure)),
+			expected: &ExpectedError{Cause: &ExpectedError{Kind: ErrorKindCanceled}},
+			failure:  "cause of childWorkflow error: expected canceled error, got terminated error",
+		},
+		{
+			name:     "missing cause",
+			err:      temporal.NewApplicationError("boom", "MyError"),
+			expected: &ExpectedError{Cause: &ExpectedError{}},
+			failure:  "cause of application error: expected error, got no error",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.CheckError(tt.err, tt.expected)
+			if tt.failure == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.failure)
+			}
+		})
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/nexus-rpc/sdk-go/nexus"
+	"github.com/temporalio/features/harness/go/history"
 	"github.com/urfave/cli/v2"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
@@ -40,12 +42,28 @@ type Feature struct {
 	// endpoint created targeting their task queue, accessible via Runner.NexusEndpoint.
 	NexusServices interface{}
 
+	// Additional named workers, each with its own task queue, registrations, and
+	// options. They are started with the feature's worker unless ManualStart is
+	// set, and can be started, stopped, and restarted by name on the Runner.
+	// Replay registers the workflows of all of them.
+	Workers []FeatureWorker
+
+	// Table of cases to run the feature with. Each case is run and reported as a
+	// separate feature named "<dir>+<case>" with its own task queue, and values
+	// set on the case override the feature's. See FeatureCase.
+	Cases []FeatureCase
+
 	// If present, expects workflow to fail with this activity error string.
 	ExpectActivityError string
 
-	// If present, expects workflow to succeed with this value.
+	// If present, expects workflow to succeed with this value. Use NilRunResult
+	// to expect a nil result, since nil means no result is expected.
 	ExpectRunResult interface{}
 
+	// If present, expects workflow to fail with an error matching this. Cannot be
+	// used with ExpectActivityError or ExpectRunResult.
+	ExpectError *ExpectedError
+
 	// Client options for client creation. Some values like HostPort are always
 	// overridden internally.
 	ClientOptions client.Options
@@ -66,6 +84,20 @@ type Feature struct {
 	// provided via the StartWorkflowOptions field will be honored.
 	DisableWorkflowPanicPolicyOverride bool
 
+	// If present, called by NewRunner before the client is created and the worker
+	// is started. The client is not available yet, but the feature's client and
+	// worker options on runner.Feature can be changed. If this returns an error,
+	// the runner is not created and Teardown is not called.
+	Setup func(ctx context.Context, runner *Runner) error
+
+	// If present, called once when the runner is closed if its client was
+	// created, including when starting workers, execution, or checks fail or
+	// panic. It is not called if Setup fails or the client cannot be created, so
+	// the client is always available. Workers are stopped before this is called.
+	// The context keeps the run's values but is not canceled with it, and times
+	// out after TeardownTimeout.
+	Teardown func(ctx context.Context, runner *Runner)
+
 	// Default is runner.ExecuteDefault which just runs the first workflow with no
 	// params. If this returns a nil run, no replay or checks are performed. This
 	// allows for advanced tests that do not want to test history.
@@ -83,6 +115,70 @@ type Feature struct {
 	SkipReason string
 }
 
+type nilRunResult struct{}
+
+// NilRunResult is an ExpectRunResult that expects the workflow to succeed with
+// a nil result.
+var NilRunResult interface{} = nilRunResult{}
+
+// FeatureWorker is an additional named worker of a feature.
+type FeatureWorker struct {
+	// Name of the worker, unique within the feature. Required.
+	Name string
+
+	// Appended to the feature's task queue to get this worker's task queue. If
+	// empty, the worker polls the feature's task queue, e.g. as another build of
+	// the feature's worker.
+	TaskQueueSuffix string
+
+	// Set of workflows to register, same as Feature.Workflows.
+	Workflows interface{}
+
+	// Set of activities to register, same as Feature.Activities.
+	Activities interface{}
+
+	// Set of Nexus services to register, same as Feature.NexusServices.
+	NexusServices interface{}
+
+	// Worker options for worker creation. The WorkflowPanicPolicy is overridden
+	// the same as Feature.WorkerOptions.
+	Options worker.Options
+
+	// If true, the worker is not started with the feature's worker and must be
+	// started with Runner.StartNamedWorker.
+	ManualStart bool
+}
+
+// FeatureCase is a case of a table-driven feature. The case being run is
+// PreparedFeature.Case, so custom Execute and check functions can use its
+// input.
+type FeatureCase struct {
+	// Name of the case, unique within the feature. Since histories are stored per
+	// case, it has the same restrictions as run variant names. Required.
+	Name string
+
+	// Arguments the default executor starts the primary workflow with.
+	Args []interface{}
+
+	// Any other input of the case for custom functions.
+	Input interface{}
+
+	// If present, overrides Feature.ExpectActivityError.
+	ExpectActivityError string
+
+	// If present, overrides Feature.ExpectRunResult. Use NilRunResult to
+	// expect a nil result.
+	ExpectRunResult interface{}
+
+	// If present, overrides Feature.ExpectError. Since ExpectError cannot be
+	// used with the other expectations, setting it on either the case or the
+	// feature means the other's are not used.
+	ExpectError *ExpectedError
+
+	// If present, called after the feature's StartWorkflowOptionsMutator.
+	StartWorkflowOptionsMutator func(*client.StartWorkflowOptions)
+}
+
 type WorkflowWithOptions struct {
 	Workflow interface{}
 	Options  workflow.RegisterOptions
@@ -104,6 +200,123 @@ type PreparedFeature struct {
 	Workflows     []interface{}
 	Activities    []interface{}
 	NexusServices []*nexus.Service
+	Workers       []*PreparedWorker
+	// The case being run, set by WithCase. Nil if the feature has no cases or
+	// all of them are run.
+	Case *FeatureCase
+}
+
+// PreparedWorker is a FeatureWorker with its registrations as slices.
+type PreparedWorker struct {
+	FeatureWorker
+	Workflows     []interface{}
+	Activities    []interface{}
+	NexusServices []*nexus.Service
+}
+
+// GetWorker returns the named worker, or nil if the feature has none by that
+// name.
+func (p *PreparedFeature) GetWorker(name string) *PreparedWorker {
+	for _, w := range p.Workers {
+		if w.Name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// validateWorkflowRegistrations checks that workers on the same task queue only
+// register the same workflow type as different builds, so each history has one
+// worker to replay it with.
+func (p *PreparedFeature) validateWorkflowRegistrations() error {
+	type registration struct{ taskQueueSuffix, workflowType, buildID string }
+	registeredBy := map[registration]string{}
+	register := func(name, taskQueueSuffix string, options worker.Options, workflows []interface{}) error {
+		for _, workflow := range workflows {
+			key := registration{taskQueueSuffix, workflowTypeName(workflow), workerBuildID(options)}
+			if other, ok := registeredBy[key]; ok {
+				return fmt.Errorf("%v and %v both register workflow %v on the same task queue as the same build, "+
+					"only different builds may register the same workflow type", other, name, key.workflowType)
+			}
+			registeredBy[key] = name
+		}
+		return nil
+	}
+	if err := register("feature worker", "", p.WorkerOptions, p.Workflows); err != nil {
+		return err
+	}
+	for _, w := range p.Workers {
+		if err := register("feature worker "+w.Name, w.TaskQueueSuffix, w.Options, w.Workflows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workerBuildID returns the build ID a worker with the options runs as, or
+// empty if none.
+func workerBuildID(options worker.Options) string {
+	if options.DeploymentOptions.UseVersioning {
+		return options.DeploymentOptions.Version.BuildID
+	}
+	return options.BuildID
+}
+
+// TaskQueueSuffixes returns the distinct non-empty task queue suffixes of the
+// feature's named workers, whose task queues must be checked alongside the
+// feature's own.
+func (p *PreparedFeature) TaskQueueSuffixes() []string {
+	var suffixes []string
+	for _, w := range p.Workers {
+		if w.TaskQueueSuffix != "" && !slices.Contains(suffixes, w.TaskQueueSuffix) {
+			suffixes = append(suffixes, w.TaskQueueSuffix)
+		}
+	}
+	return suffixes
+}
+
+// GetCase returns the named case, or nil if the feature has none by that name.
+func (p *PreparedFeature) GetCase(name string) *FeatureCase {
+	for i := range p.Cases {
+		if p.Cases[i].Name == name {
+			return &p.Cases[i]
+		}
+	}
+	return nil
+}
+
+// WithCase returns a copy of the feature for running the named case, with the
+// case's values overriding the feature's.
+func (p *PreparedFeature) WithCase(name string) (*PreparedFeature, error) {
+	featureCase := p.GetCase(name)
+	if featureCase == nil {
+		return nil, fmt.Errorf("feature %v has no case %v", p.Dir, name)
+	}
+	featureCopy := *p
+	featureCopy.Case = featureCase
+	if featureCase.ExpectActivityError != "" {
+		featureCopy.ExpectActivityError = featureCase.ExpectActivityError
+		featureCopy.ExpectError = nil
+	}
+	if featureCase.ExpectRunResult != nil {
+		featureCopy.ExpectRunResult = featureCase.ExpectRunResult
+		featureCopy.ExpectError = nil
+	}
+	if featureCase.ExpectError != nil {
+		featureCopy.ExpectActivityError = ""
+		featureCopy.ExpectRunResult = nil
+		featureCopy.ExpectError = featureCase.ExpectError
+	}
+	if featureCase.StartWorkflowOptionsMutator != nil {
+		featureMutator := p.StartWorkflowOptionsMutator
+		featureCopy.StartWorkflowOptionsMutator = func(opts *client.StartWorkflowOptions) {
+			if featureMutator != nil {
+				featureMutator(opts)
+			}
+			featureCase.StartWorkflowOptionsMutator(opts)
+		}
+	}
+	return &featureCopy, nil
 }
 
 func (p *PreparedFeature) GetPrimaryWorkflow() (*WorkflowWithOptions, error) {
@@ -155,12 +368,44 @@ func PrepareFeature(feature Feature) (*PreparedFeature, error) {
 		Workflows:  rawToSlice(feature.Workflows),
 		Activities: rawToSlice(feature.Activities),
 	}
-	for _, raw := range rawToSlice(feature.NexusServices) {
-		svc, ok := raw.(*nexus.Service)
-		if !ok {
-			return nil, fmt.Errorf("nexus service must be *nexus.Service, got %T", raw)
+	var err error
+	if p.NexusServices, err = nexusServices(feature.NexusServices); err != nil {
+		return nil, err
+	}
+	for _, w := range feature.Workers {
+		if w.Name == "" {
+			return nil, fmt.Errorf("feature worker missing name")
+		} else if p.GetWorker(w.Name) != nil {
+			return nil, fmt.Errorf("duplicate feature worker %v", w.Name)
+		}
+		prepared := &PreparedWorker{
+			FeatureWorker: w,
+			Workflows:     rawToSlice(w.Workflows),
+			Activities:    rawToSlice(w.Activities),
+		}
+		if prepared.NexusServices, err = nexusServices(w.NexusServices); err != nil {
+			return nil, fmt.Errorf("feature worker %v: %w", w.Name, err)
+		}
+		p.Workers = append(p.Workers, prepared)
+	}
+	if err := p.validateWorkflowRegistrations(); err != nil {
+		return nil, err
+	}
+	if feature.ExpectError != nil && (feature.ExpectActivityError != "" || feature.ExpectRunResult != nil) {
+		return nil, fmt.Errorf("ExpectError cannot be used with ExpectActivityError or ExpectRunResult")
+	}
+	caseNames := map[string]bool{}
+	for _, featureCase := range feature.Cases {
+		if err := history.ValidateVariant(featureCase.Name); err != nil {
+			return nil, fmt.Errorf("invalid feature case: %w", err)
+		} else if strings.ContainsAny(featureCase.Name, "#:") {
+			return nil, fmt.Errorf("feature case name %q cannot contain '#' or ':'", featureCase.Name)
+		} else if featureCase.ExpectError != nil && (featureCase.ExpectActivityError != "" || featureCase.ExpectRunResult != nil) {
+			return nil, fmt.Errorf("feature case %v: ExpectError cannot be used with ExpectActivityError or ExpectRunResult", featureCase.Name)
+		} else if caseNames[featureCase.Name] {
+			return nil, fmt.Errorf("duplicate feature case %v", featureCase.Name)
 		}
-		p.NexusServices = append(p.NexusServices, svc)
+		caseNames[featureCase.Name] = true
 	}
 	// If it's skipped, just return it
 	if p.SkipReason != "" {
@@ -210,6 +455,18 @@ func CreateSubprocessCommand(ctx context.Context, name string, args ...string) (
 	return cmd, nil
 }
 
+func nexusServices(v interface{}) ([]*nexus.Service, error) {
+	var services []*nexus.Service
+	for _, raw := range rawToSlice(v) {
+		svc, ok := raw.(*nexus.Service)
+		if !ok {
+			return nil, fmt.Errorf("nexus service must be *nexus.Service, got %T", raw)
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
 func rawToSlice(v interface{}) []interface{} {
 	val := reflect.ValueOf(v)
 	if !val.IsValid() {
@@ -0,0 +1,155 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+func TestPreparedFeatureTaskQueueSuffixes(t *testing.T) {
+	// Skipped so the test workflow need not be beneath the features dir
+	feature, err := PrepareFeature(Feature{
+		SkipReason: "test",
+		Workflows:  replayTestWorkflow,
+		Workers: []FeatureWorker{
+			{Name: "v1", TaskQueueSuffix: "-v1", Workflows: replayTestWorkflow},
+			{Name: "v1-again", TaskQueueSuffix: "-v1", Workflows: replayTestWorkflow, Options: worker.Options{BuildID: "2.0"}},
+			{Name: "shared", Workflows: replayTestWorkflow, Options: worker.Options{BuildID: "2.0"}},
+			{Name: "v2", TaskQueueSuffix: "-v2", Workflows: replayTestWorkflow},
+		},
+	})
+	require.NoError(t, err)
+	// Workers on the feature's own task queue and repeated suffixes add nothing
+	assert.Equal(t, []string{"-v1", "-v2"}, feature.TaskQueueSuffixes())
+}
+
+func TestPrepareFeatureRejectsSameWorkflowOfSameBuildOnTaskQueue(t *testing.T) {
+	prepare := func(workers ...FeatureWorker) error {
+		_, err := PrepareFeature(Feature{SkipReason: "test", Workflows: replayTestWorkflow, Workers: workers})
+		return err
+	}
+	assert.ErrorContains(t, prepare(FeatureWorker{Name: "shared", Workflows: replayTestWorkflow}),
+		"feature worker and feature worker shared both register workflow replayTestWorkflow")
+	assert.Error(t, prepare(
+		FeatureWorker{Name: "v1", TaskQueueSuffix: "-v1", Workflows: replayTestWorkflow},
+		FeatureWorker{Name: "v1-again", TaskQueueSuffix: "-v1", Workflows: replayTestWorkflow},
+	))
+	deployment := func(buildID string) worker.Options {
+		return worker.Options{DeploymentOptions: worker.DeploymentOptions{
+			UseVersioning: true,
+			Version:       worker.WorkerDeploymentVersion{DeploymentName: "deployment", BuildID: buildID},
+		}}
+	}
+	assert.Error(t, prepare(
+		FeatureWorker{Name: "v1", Workflows: replayTestWorkflow, Options: deployment("")},
+	))
+	// Different builds, or other task queues, may register the same type
+	assert.NoError(t, prepare(
+		FeatureWorker{Name: "v2", Workflows: replayTestWorkflow, Options: deployment("2.0")},
+		FeatureWorker{Name: "v3", Workflows: replayTestWorkflow, Options: worker.Options{BuildID: "3.0"}},
+		FeatureWorker{Name: "other", TaskQueueSuffix: "-other", Workflows: replayTestWorkflow},
+	))
+}
+
+func TestPrepareFeatureValidatesCases(t *testing.T) {
+	for _, cases := range [][]FeatureCase{
+		{{}},
+		{{Name: "a.b"}},
+		{{Name: "a+b"}},
+		{{Name: "v2"}},
+		{{Name: "a#b"}},
+		{{Name: "a:b"}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", ExpectError: &ExpectedError{}, ExpectRunResult: "result"}},
+	} {
+		_, err := PrepareFeature(Feature{SkipReason: "test", Cases: cases})
+		assert.Error(t, err, "cases %+v", cases)
+	}
+	_, err := PrepareFeature(Feature{SkipReason: "test", Cases: []FeatureCase{{Name: "a-b"}, {Name: "c"}}})
+	assert.NoError(t, err)
+}
+
+func TestPreparedFeatureWithCase(t *testing.T) {
+	feature, err := PrepareFeature(Feature{
+		SkipReason:      "test",
+		ExpectRunResult: "feature",
+		StartWorkflowOptionsMutator: func(opts *client.StartWorkflowOptions) {
+			opts.ID = "feature"
+		},
+		Cases: []FeatureCase{
+			{Name: "default"},
+			{
+				Name:            "override",
+				ExpectRunResult: "case",
+				StartWorkflowOptionsMutator: func(opts *client.StartWorkflowOptions) {
+					opts.ID += "-case"
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	_, err = feature.WithCase("unknown")
+	assert.Error(t, err)
+
+	// A case without values of its own runs the feature as is
+	defaultCase, err := feature.WithCase("default")
+	require.NoError(t, err)
+	assert.Equal(t, "default", defaultCase.Case.Name)
+	assert.Equal(t, "feature", defaultCase.ExpectRunResult)
+	var opts client.StartWorkflowOptions
+	defaultCase.StartWorkflowOptionsMutator(&opts)
+	assert.Equal(t, "feature", opts.ID)
+
+	// A case's values override the feature's, and its mutator runs after the
+	// feature's
+	overrideCase, err := feature.WithCase("override")
+	require.NoError(t, err)
+	assert.Equal(t, "case", overrideCase.ExpectRunResult)
+	opts = client.StartWorkflowOptions{}
+	overrideCase.StartWorkflowOptionsMutator(&opts)
+	assert.Equal(t, "feature-case", opts.ID)
+
+	// The feature itself is unchanged
+	assert.Nil(t, feature.Case)
+	assert.Equal(t, "feature", feature.ExpectRunResult)
+}
+
+func TestPreparedFeatureWithCaseExpectations(t *testing.T) {
+	expectedErr := &ExpectedError{Kind: ErrorKindCanceled}
+	feature, err := PrepareFeature(Feature{
+		SkipReason:      "test",
+		ExpectRunResult: "feature",
+		Cases: []FeatureCase{
+			{Name: "activity-error", ExpectActivityError: "boom"},
+			{Name: "error", ExpectError: expectedErr},
+		},
+	})
+	require.NoError(t, err)
+
+	// Each expectation of the case only overrides the same one of the feature
+	activityErrorCase, err := feature.WithCase("activity-error")
+	require.NoError(t, err)
+	assert.Equal(t, "boom", activityErrorCase.ExpectActivityError)
+	assert.Equal(t, "feature", activityErrorCase.ExpectRunResult)
+
+	// ExpectError excludes the other expectations of the feature
+	errorCase, err := feature.WithCase("error")
+	require.NoError(t, err)
+	assert.Same(t, expectedErr, errorCase.ExpectError)
+	assert.Nil(t, errorCase.ExpectRunResult)
+
+	// And the feature's is excluded by the other expectations of a case
+	feature, err = PrepareFeature(Feature{
+		SkipReason:  "test",
+		ExpectError: expectedErr,
+		Cases:       []FeatureCase{{Name: "result", ExpectRunResult: "case"}},
+	})
+	require.NoError(t, err)
+	resultCase, err := feature.WithCase("result")
+	require.NoError(t, err)
+	assert.Nil(t, resultCase.ExpectError)
+	assert.Equal(t, "case", resultCase.ExpectRunResult)
+}
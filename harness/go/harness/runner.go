@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +17,7 @@ import (
 
 	"golang.org/x/mod/semver"
 
+	"github.com/nexus-rpc/sdk-go/nexus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/temporalio/features/harness/go/history"
@@ -35,8 +38,11 @@ type skipFeatureError struct {
 // Runner represents a runner that can run a feature.
 type Runner struct {
 	RunnerConfig
-	Client     client.Client
-	Worker     worker.Worker
+	Client client.Client
+	Worker worker.Worker
+	// Running named workers of the feature by name. Use StartNamedWorker and
+	// StopNamedWorker instead of changing this.
+	Workers    map[string]worker.Worker
 	Feature    *PreparedFeature
 	CreateTime time.Time
 
@@ -45,8 +51,20 @@ type Runner struct {
 	SoftAssert    *assert.Assertions
 	LastAssertErr error
 	Require       *require.Assertions
+
+	// Whether the feature's Teardown is yet to be called by Close
+	teardownPending bool
+	// Context for Teardown, from NewRunner's without its cancellation
+	teardownCtx context.Context
 }
 
+// DefaultWorkflowExecutionTimeout is the execution timeout of workflows started
+// by the default executions.
+const DefaultWorkflowExecutionTimeout = 1 * time.Minute
+
+// TeardownTimeout bounds how long a feature's Teardown may take.
+const TeardownTimeout = 1 * time.Minute
+
 // RunnerConfig is configuration for NewRunner.
 type RunnerConfig struct {
 	ServerHostPort string
@@ -61,10 +79,24 @@ type RunnerConfig struct {
 	Log            log.Logger
 	HTTPProxyURL   string
 	TLSServerName  string
+	// HistoryCompare is the feature's historyCompare config. Its version
+	// policies decide which stored histories are replayed, and its scrub rules
+	// are applied by ScrubHistories.
+	HistoryCompare history.CompareConfig
+}
+
+// NewReplayRunner creates a runner for the given config and feature that only
+// replays histories. It has no client or worker and never contacts a server.
+func NewReplayRunner(config RunnerConfig, feature *PreparedFeature) *Runner {
+	if config.Log == nil {
+		config.Log = DefaultLogger
+	}
+	return &Runner{RunnerConfig: config, Feature: feature}
 }
 
-// NewRunner creates a new runner for the given config and feature.
-func NewRunner(config RunnerConfig, feature *PreparedFeature) (*Runner, error) {
+// NewRunner creates a new runner for the given config and feature, calling the
+// feature's Setup first if present.
+func NewRunner(ctx context.Context, config RunnerConfig, feature *PreparedFeature) (*Runner, error) {
 	if config.ServerHostPort == "" {
 		config.ServerHostPort = client.DefaultHostPort
 	}
@@ -80,6 +112,9 @@ func NewRunner(config RunnerConfig, feature *PreparedFeature) (*Runner, error) {
 	}))
 	r.Require = require.New(&requireTestingPanic{})
 
+	// Teardown must still run once the run is canceled or timed out
+	r.teardownCtx = context.WithoutCancel(ctx)
+
 	// Close on failure
 	success := false
 	defer func() {
@@ -88,6 +123,13 @@ func NewRunner(config RunnerConfig, feature *PreparedFeature) (*Runner, error) {
 		}
 	}()
 
+	// Setup may change options, so it is called before any are overridden
+	if r.Feature.Setup != nil {
+		if err := r.Feature.Setup(ctx, r); err != nil {
+			return nil, fmt.Errorf("failed setting up feature: %w", err)
+		}
+	}
+
 	// Create client
 	r.Feature.ClientOptions.HostPort = r.ServerHostPort
 	r.Feature.ClientOptions.Namespace = r.Namespace
@@ -104,6 +146,8 @@ func NewRunner(config RunnerConfig, feature *PreparedFeature) (*Runner, error) {
 	if r.Client, err = client.Dial(r.Feature.ClientOptions); err != nil {
 		return nil, fmt.Errorf("failed creating client: %w", err)
 	}
+	// Teardown can rely on the client, so it is only called once there is one
+	r.teardownPending = r.Feature.Teardown != nil
 
 	// Create worker
 	r.CreateTime = time.Now()
@@ -114,6 +158,13 @@ func NewRunner(config RunnerConfig, feature *PreparedFeature) (*Runner, error) {
 	if err != nil {
 		return nil, err
 	}
+	for _, w := range r.Feature.Workers {
+		if !w.ManualStart {
+			if err := r.StartNamedWorker(w.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	success = true
 	return r, nil
@@ -158,22 +209,26 @@ func (r *Runner) Run(ctx context.Context) error {
 	return err
 }
 
-// ExecuteDefault is the default execution that just runs the first workflow and
-// assumes it takes no parameters.
+// ExecuteDefault is the default execution that just runs the first workflow
+// with the arguments of the feature case being run, if any.
 func (r *Runner) ExecuteDefault(ctx context.Context) (client.WorkflowRun, error) {
 	opts := client.StartWorkflowOptions{
 		TaskQueue:                r.TaskQueue,
-		WorkflowExecutionTimeout: 1 * time.Minute,
+		WorkflowExecutionTimeout: DefaultWorkflowExecutionTimeout,
 	}
 	r.Feature.StartWorkflowOptionsMutator(&opts)
 	firstWorkflow, err := r.Feature.GetPrimaryWorkflow()
 	if err != nil {
 		return nil, err
 	}
+	var args []interface{}
+	if r.Feature.Case != nil {
+		args = r.Feature.Case.Args
+	}
 	if firstWorkflow.Options.Name == "" {
-		return r.Client.ExecuteWorkflow(ctx, opts, firstWorkflow.Workflow)
+		return r.Client.ExecuteWorkflow(ctx, opts, firstWorkflow.Workflow, args...)
 	}
-	return r.Client.ExecuteWorkflow(ctx, opts, firstWorkflow.Options.Name)
+	return r.Client.ExecuteWorkflow(ctx, opts, firstWorkflow.Options.Name, args...)
 }
 
 // CheckResultDefault performs the default result checks which just waits on
@@ -181,15 +236,22 @@ func (r *Runner) ExecuteDefault(ctx context.Context) (client.WorkflowRun, error)
 func (r *Runner) CheckResultDefault(ctx context.Context, run client.WorkflowRun) error {
 	// If there's an expectation of result, build pointer to hold it
 	var actualPtr interface{}
-	if r.Feature.ExpectRunResult != nil {
-		actualPtr = reflect.New(reflect.TypeOf(r.Feature.ExpectRunResult)).Interface()
+	expectedResult := r.Feature.ExpectRunResult
+	if expectedResult == NilRunResult {
+		// Decoded as is, so only nil is equal
+		expectedResult = nil
+		actualPtr = new(interface{})
+	} else if expectedResult != nil {
+		actualPtr = reflect.New(reflect.TypeOf(expectedResult)).Interface()
 	}
 
 	// Wait for completion
 	err := run.Get(ctx, actualPtr)
 
 	// If an error is expected, check it
-	if r.Feature.ExpectActivityError != "" {
+	if r.Feature.ExpectError != nil {
+		return r.CheckError(err, r.Feature.ExpectError)
+	} else if r.Feature.ExpectActivityError != "" {
 		var actErr *temporal.ActivityError
 		if !errors.As(err, &actErr) {
 			return fmt.Errorf("expected activity error, got: %w", err)
@@ -202,7 +264,7 @@ func (r *Runner) CheckResultDefault(ctx context.Context, run client.WorkflowRun)
 
 	// If result is expected, check it
 	if actualPtr != nil {
-		err = r.CheckAssertion(r.SoftAssert.Equal(r.Feature.ExpectRunResult, reflect.ValueOf(actualPtr).Elem().Interface()))
+		err = r.CheckAssertion(r.SoftAssert.Equal(expectedResult, reflect.ValueOf(actualPtr).Elem().Interface()))
 		if err != nil {
 			return err
 		}
@@ -211,6 +273,15 @@ func (r *Runner) CheckResultDefault(ctx context.Context, run client.WorkflowRun)
 	return nil
 }
 
+// ScrubHistories removes run-specific fields from the histories, then applies
+// the feature's scrub rules on top, the same way the runner does before it
+// compares histories. Custom CheckHistory functions that compare histories
+// should scrub both sides with this.
+func (r *Runner) ScrubHistories(histories history.Histories) error {
+	histories.ScrubRunSpecificFields()
+	return histories.ApplyScrubRules(r.HistoryCompare.Scrub)
+}
+
 // CheckHistoryDefault is the default history checker which fetches the history
 // and replays it to confirm it succeeds. It also replays all other histories
 // for versions <= the current SDK version.
@@ -221,10 +292,11 @@ func (r *Runner) CheckHistoryDefault(ctx context.Context, _ client.WorkflowRun)
 	// First check our own history
 	r.Log.Debug("Checking current execution replay", "Feature", r.Feature.Dir)
 	fetcher := &history.Fetcher{
-		Client:         r.Client,
-		Namespace:      r.Namespace,
-		TaskQueue:      r.TaskQueue,
-		FeatureStarted: r.CreateTime,
+		Client:            r.Client,
+		Namespace:         r.Namespace,
+		TaskQueue:         r.TaskQueue,
+		TaskQueueSuffixes: r.Feature.TaskQueueSuffixes(),
+		FeatureStarted:    r.CreateTime,
 	}
 	histories, err := fetcher.Fetch(ctx)
 	if err != nil {
@@ -233,58 +305,265 @@ func (r *Runner) CheckHistoryDefault(ctx context.Context, _ client.WorkflowRun)
 	if err := r.ReplayHistories(ctx, histories); err != nil {
 		return fmt.Errorf("failed replaying current execution: %w", err)
 	}
+	return r.ReplayStoredHistories(ctx)
+}
 
-	// Now load up all other histories
+// ExpectHistory fetches the full history of the run for assertions with the
+// history package's event matchers, e.g. in a feature's CheckHistory:
+//
+//	exp, err := runner.ExpectHistory(ctx, run)
+//	if err != nil {
+//		return err
+//	}
+//	return exp.InOrder(history.ActivityScheduled("Foo"), history.WorkflowCompleted())
+func (r *Runner) ExpectHistory(ctx context.Context, run client.WorkflowRun) (*history.Expectation, error) {
+	hist := &historypb.History{}
+	iter := r.Client.GetWorkflowHistory(ctx, run.GetID(), run.GetRunID(), false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed fetching history: %w", err)
+		}
+		hist.Events = append(hist.Events, event)
+	}
+	return history.Expect(hist), nil
+}
+
+// ReplayStoredHistories replays every stored history of the feature returned
+// by StoredHistoryFiles. This does not need a client.
+func (r *Runner) ReplayStoredHistories(ctx context.Context) error {
+	files, err := r.StoredHistoryFiles()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		r.Log.Debug("Checking previous history replay", "Feature", r.Feature.Dir,
+			"Variant", file.Variant, "Version", file.Version)
+		histories, err := history.ReadFile(file.Path, file.Format)
+		if err != nil {
+			return fmt.Errorf("failed loading histories: %w", err)
+		} else if err := r.ReplayHistories(ctx, histories); err != nil {
+			return fmt.Errorf("failed replaying history version %v: %w", describeVersion(file.Variant, file.Version), err)
+		}
+	}
+	return nil
+}
+
+// StoredHistoryFiles returns the stored Go history files of the feature, of
+// every run variant, for this version or earlier that the feature's version
+// policy does not ignore. These are the files that must replay successfully.
+func (r *Runner) StoredHistoryFiles() ([]*history.StoredFile, error) {
 	storage := &history.Storage{Dir: filepath.Join(r.Feature.AbsDir, "history"), Lang: "go"}
-	set, err := storage.Load()
+	files, err := storage.Files()
 	if err != nil {
-		return fmt.Errorf("failed loading histories: %w", err)
+		return nil, fmt.Errorf("failed loading histories: %w", err)
 	}
 
-	// Go over each history, and every one that's on or before this version should
-	// replay successfully
-	for version, histories := range set.ByVersion {
+	// Every history that's on or before this version should replay successfully
+	// unless the feature's version policy ignores it. Run variants only change
+	// the server, so histories of all of them replay.
+	var replayable []*history.StoredFile
+	for _, file := range files {
 		// Don't include newer histories
-		if semver.Compare(version, SDKVersion) > 0 {
-			r.Log.Debug("Skipping history because it's for later version", "Feature", r.Feature.Dir, "Version", version)
+		if semver.Compare(file.Version, SDKVersion) > 0 {
+			r.Log.Debug("Skipping history because it's for later version", "Feature", r.Feature.Dir,
+				"Variant", file.Variant, "Version", file.Version)
+			continue
+		} else if r.HistoryCompare.VersionCheck("go", SDKVersion, file.Version) == history.VersionCheckIgnore {
+			r.Log.Debug("Skipping history ignored by version policy", "Feature", r.Feature.Dir,
+				"Variant", file.Variant, "Version", file.Version)
 			continue
 		}
+		replayable = append(replayable, file)
+	}
+	return replayable, nil
+}
 
-		r.Log.Debug("Checking previous history replay", "Feature", r.Feature.Dir, "Version", version)
-		if err := r.ReplayHistories(ctx, histories); err != nil {
-			return fmt.Errorf("failed replaying history version %v: %w", version, err)
+// describeVersion returns the history version, qualified with its variant if
+// any.
+func describeVersion(variant, version string) string {
+	if variant == "" {
+		return version
+	}
+	return version + " of variant " + variant
+}
+
+// CrossLanguageReplayError is returned by ReplayCrossLanguageHistories when
+// stored histories of other languages fail replay.
+type CrossLanguageReplayError struct {
+	Failures []*CrossLanguageReplayFailure
+}
+
+// CrossLanguageReplayFailure is a single failed history file of another
+// language.
+type CrossLanguageReplayFailure struct {
+	Lang    string
+	Variant string
+	Version string
+	Err     error
+}
+
+func (c *CrossLanguageReplayError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cross-language replay failed for %v history file(s)", len(c.Failures))
+	for _, failure := range c.Failures {
+		fmt.Fprintf(&b, "\n  %v %v: %v", failure.Lang, describeVersion(failure.Variant, failure.Version), failure.Err)
+	}
+	return b.String()
+}
+
+// ReplayCrossLanguageHistories replays every stored history of languages other
+// than Go, regardless of version, unless the feature's version policy ignores
+// it. All files are attempted and failures are collected into a
+// *CrossLanguageReplayError. This does not need a client.
+func (r *Runner) ReplayCrossLanguageHistories(ctx context.Context) error {
+	var replayErr CrossLanguageReplayError
+	for _, lang := range history.Languages {
+		if lang == "go" {
+			continue
+		}
+		storage := &history.Storage{Dir: filepath.Join(r.Feature.AbsDir, "history"), Lang: lang}
+		files, err := storage.Files()
+		if err != nil {
+			return fmt.Errorf("failed loading %v histories: %w", lang, err)
+		}
+		for _, file := range files {
+			// The current version of another language is not known here
+			if r.HistoryCompare.VersionCheck(lang, "", file.Version) == history.VersionCheckIgnore {
+				r.Log.Debug("Skipping history ignored by version policy", "Feature", r.Feature.Dir,
+					"Lang", lang, "Variant", file.Variant, "Version", file.Version)
+				continue
+			}
+			r.Log.Debug("Checking cross-language history replay", "Feature", r.Feature.Dir,
+				"Lang", lang, "Variant", file.Variant, "Version", file.Version)
+			histories, err := history.ReadFile(file.Path, file.Format)
+			if err != nil {
+				return fmt.Errorf("failed loading %v histories: %w", lang, err)
+			} else if err := r.ReplayHistories(ctx, histories); err != nil {
+				replayErr.Failures = append(replayErr.Failures, &CrossLanguageReplayFailure{
+					Lang:    lang,
+					Variant: file.Variant,
+					Version: file.Version,
+					Err:     err,
+				})
+			}
 		}
 	}
+	if len(replayErr.Failures) > 0 {
+		return &replayErr
+	}
 	return nil
 }
 
-// ReplayHistories replays the given histories checking for errors.
+// ReplayHistories replays the given histories checking for errors. Named
+// workers may register other implementations of the same workflow type, so
+// each history is replayed with the workflows of the worker that ran it: the
+// named worker whose task queue suffix ends the history's task queue, or else
+// the feature's worker and the named workers polling its task queue. Workers
+// on the same task queue only register the same workflow type as different
+// builds, so if several register it, the one of the build that completed the
+// history's last workflow task replays it.
 func (r *Runner) ReplayHistories(ctx context.Context, histories history.Histories) error {
-	// Create replayer with all the workflow funcs
-	replayer, err := worker.NewWorkflowReplayerWithOptions(
-		worker.WorkflowReplayerOptions{DataConverter: r.Feature.ClientOptions.DataConverter},
-	)
-	if err != nil {
+	// Create a replayer per worker with its workflow funcs
+	type workerReplayer struct {
+		worker.WorkflowReplayer
+		taskQueueSuffix string
+		buildID         string
+		workflowTypes   map[string]bool
+	}
+	var replayers []*workerReplayer
+	addReplayer := func(taskQueueSuffix string, options worker.Options, workflows []interface{}) error {
+		replayer, err := worker.NewWorkflowReplayerWithOptions(
+			worker.WorkflowReplayerOptions{DataConverter: r.Feature.ClientOptions.DataConverter},
+		)
+		if err != nil {
+			return err
+		}
+		workflowTypes := map[string]bool{}
+		for _, workflow := range workflows {
+			workflowTypes[workflowTypeName(workflow)] = true
+			registerWorkflow(replayer, workflow)
+		}
+		replayers = append(replayers, &workerReplayer{replayer, taskQueueSuffix, workerBuildID(options), workflowTypes})
+		return nil
+	}
+	if err := addReplayer("", r.Feature.WorkerOptions, r.Feature.Workflows); err != nil {
 		return err
 	}
-	for _, workflow := range r.Feature.Workflows {
-		switch workflow.(type) {
-		case WorkflowWithOptions:
-			casted := workflow.(WorkflowWithOptions)
-			replayer.RegisterWorkflowWithOptions(casted.Workflow, casted.Options)
-		default:
-			replayer.RegisterWorkflow(workflow)
+	for _, w := range r.Feature.Workers {
+		if err := addReplayer(w.TaskQueueSuffix, w.Options, w.Workflows); err != nil {
+			return err
 		}
 	}
 	// Replay each
-	for _, history := range histories {
-		if err := replayer.ReplayWorkflowHistory(nil, history); err != nil {
-			return err
+	for i, history := range histories {
+		// Empty or truncated histories have no started event to name, and any
+		// replayer fails them
+		if len(history.GetEvents()) == 0 {
+			if err := replayers[0].ReplayWorkflowHistory(nil, history); err != nil {
+				return fmt.Errorf("history %v: %w", i, err)
+			}
+			continue
+		}
+		attrs := history.GetEvents()[0].GetWorkflowExecutionStartedEventAttributes()
+		workflowType, taskQueue := attrs.GetWorkflowType().GetName(), attrs.GetTaskQueue().GetName()
+		// The workers that may have run it are those with the longest task queue
+		// suffix matching, which is none for the feature's task queue. Of those,
+		// only the ones registering the workflow type can, unless none do so the
+		// error says it is not registered.
+		var longestSuffix string
+		for _, replayer := range replayers {
+			if strings.HasSuffix(taskQueue, replayer.taskQueueSuffix) && len(replayer.taskQueueSuffix) > len(longestSuffix) {
+				longestSuffix = replayer.taskQueueSuffix
+			}
+		}
+		var candidates, unregistered []*workerReplayer
+		for _, replayer := range replayers {
+			if replayer.taskQueueSuffix != longestSuffix {
+				continue
+			} else if replayer.workflowTypes[workflowType] {
+				candidates = append(candidates, replayer)
+			} else {
+				unregistered = append(unregistered, replayer)
+			}
+		}
+		if len(candidates) == 0 {
+			candidates = unregistered[:1]
+		} else if len(candidates) > 1 {
+			buildID := historyBuildID(history)
+			candidates = slices.DeleteFunc(candidates, func(replayer *workerReplayer) bool {
+				return replayer.buildID != buildID
+			})
+			if len(candidates) == 0 {
+				return fmt.Errorf("history %v of workflow %v: completed by build %q, which no worker registering "+
+					"the workflow on its task queue runs", i, workflowType, buildID)
+			}
+		}
+		if err := candidates[0].ReplayWorkflowHistory(nil, history); err != nil {
+			return fmt.Errorf("history %v of workflow %v: %w", i, workflowType, err)
 		}
 	}
 	return nil
 }
 
+// historyBuildID returns the build ID of the worker that completed the last
+// workflow task of the history, or empty if none is recorded.
+func historyBuildID(hist *historypb.History) string {
+	events := hist.GetEvents()
+	for i := len(events) - 1; i >= 0; i-- {
+		attrs := events[i].GetWorkflowTaskCompletedEventAttributes()
+		if attrs == nil {
+			continue
+		} else if buildID := attrs.GetDeploymentVersion().GetBuildId(); buildID != "" {
+			return buildID
+		} else if buildID := attrs.GetDeployment().GetBuildId(); buildID != "" {
+			return buildID
+		}
+		return attrs.GetWorkerVersion().GetBuildId()
+	}
+	return ""
+}
+
 func (r *Runner) Skip(reason string) error {
 	return &skipFeatureError{reason: reason}
 }
@@ -402,9 +681,22 @@ func (r *Runner) Close() {
 		r.Worker.Stop()
 		r.Worker = nil
 	}
-	if r.Client != nil {
-		r.Client.Close()
-		r.Client = nil
+	for name := range r.Workers {
+		r.StopNamedWorker(name)
+	}
+	// The client is closed even if teardown panics
+	defer func() {
+		if r.Client != nil {
+			r.Client.Close()
+			r.Client = nil
+		}
+	}()
+	if r.teardownPending {
+		r.teardownPending = false
+		r.Log.Debug("Tearing down feature", "Feature", r.Feature.Dir)
+		ctx, cancel := context.WithTimeout(r.teardownCtx, TeardownTimeout)
+		defer cancel()
+		r.Feature.Teardown(ctx, r)
 	}
 }
 
@@ -444,33 +736,105 @@ func (r *Runner) StartWorker() error {
 	r.Worker = worker.New(r.Client, r.RunnerConfig.TaskQueue, r.Feature.WorkerOptions)
 
 	// Register the workflows and activities
-	for _, workflow := range r.Feature.Workflows {
-		switch workflow.(type) {
-		case WorkflowWithOptions:
-			casted := workflow.(WorkflowWithOptions)
-			r.Worker.RegisterWorkflowWithOptions(casted.Workflow, casted.Options)
-		default:
-			r.Worker.RegisterWorkflow(workflow)
-		}
+	register(r.Worker, r.Feature.Workflows, r.Feature.Activities, r.Feature.NexusServices)
+
+	// Start the worker
+	if err := r.Worker.Start(); err != nil {
+		return fmt.Errorf("failed starting worker: %w", err)
 	}
-	for _, activity := range r.Feature.Activities {
+	return nil
+}
+
+// NamedWorkerTaskQueue returns the task queue of the feature's named worker.
+func (r *Runner) NamedWorkerTaskQueue(name string) string {
+	if w := r.Feature.GetWorker(name); w != nil {
+		return r.TaskQueue + w.TaskQueueSuffix
+	}
+	return r.TaskQueue
+}
+
+// StartNamedWorker starts the feature's named worker. It fails if the feature
+// has no worker by that name or it is already running.
+func (r *Runner) StartNamedWorker(name string) error {
+	w := r.Feature.GetWorker(name)
+	if w == nil {
+		return fmt.Errorf("feature has no worker %v", name)
+	} else if r.Workers[name] != nil {
+		return fmt.Errorf("worker %v is currently running, cannot start a new one", name)
+	}
+	options := w.Options
+	if !r.Feature.DisableWorkflowPanicPolicyOverride {
+		options.WorkflowPanicPolicy = worker.FailWorkflow
+	}
+	namedWorker := worker.New(r.Client, r.NamedWorkerTaskQueue(name), options)
+	register(namedWorker, w.Workflows, w.Activities, w.NexusServices)
+	if err := namedWorker.Start(); err != nil {
+		return fmt.Errorf("failed starting worker %v: %w", name, err)
+	}
+	if r.Workers == nil {
+		r.Workers = map[string]worker.Worker{}
+	}
+	r.Workers[name] = namedWorker
+	return nil
+}
+
+// StopNamedWorker stops the feature's named worker if it is running.
+func (r *Runner) StopNamedWorker(name string) {
+	if w := r.Workers[name]; w != nil {
+		w.Stop()
+		delete(r.Workers, name)
+	}
+}
+
+// RestartNamedWorker stops the feature's named worker if it is running and
+// starts it again.
+func (r *Runner) RestartNamedWorker(name string) error {
+	r.StopNamedWorker(name)
+	return r.StartNamedWorker(name)
+}
+
+// register registers the workflows, activities, and Nexus services on the
+// worker.
+func register(w worker.Worker, workflows, activities []interface{}, services []*nexus.Service) {
+	for _, workflow := range workflows {
+		registerWorkflow(w, workflow)
+	}
+	for _, activity := range activities {
 		switch activity.(type) {
 		case ActivityWithOptions:
 			casted := activity.(ActivityWithOptions)
-			r.Worker.RegisterActivityWithOptions(casted.Activity, casted.Options)
+			w.RegisterActivityWithOptions(casted.Activity, casted.Options)
 		default:
-			r.Worker.RegisterActivity(activity)
+			w.RegisterActivity(activity)
 		}
 	}
-	for _, service := range r.Feature.NexusServices {
-		r.Worker.RegisterNexusService(service)
+	for _, service := range services {
+		w.RegisterNexusService(service)
 	}
+}
 
-	// Start the worker
-	if err := r.Worker.Start(); err != nil {
-		return fmt.Errorf("failed starting worker: %w", err)
+func registerWorkflow(registry worker.WorkflowRegistry, workflow interface{}) {
+	switch workflow.(type) {
+	case WorkflowWithOptions:
+		casted := workflow.(WorkflowWithOptions)
+		registry.RegisterWorkflowWithOptions(casted.Workflow, casted.Options)
+	default:
+		registry.RegisterWorkflow(workflow)
 	}
-	return nil
+}
+
+// workflowTypeName returns the workflow type the workflow is registered as,
+// which is the registered name if set or else the function name the same way
+// the SDK derives it.
+func workflowTypeName(workflow interface{}) string {
+	if casted, ok := workflow.(WorkflowWithOptions); ok {
+		if casted.Options.Name != "" {
+			return casted.Options.Name
+		}
+		workflow = casted.Workflow
+	}
+	fullName := runtime.FuncForPC(reflect.ValueOf(workflow).Pointer()).Name()
+	return strings.TrimSuffix(fullName[strings.LastIndex(fullName, ".")+1:], "-fm")
 }
 
 type assertTestingFunc func(format string, args ...interface{})
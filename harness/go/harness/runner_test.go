@@ -0,0 +1,380 @@
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/temporalio/features/harness/go/history"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func replayTestWorkflow(workflow.Context) error { return nil }
+
+// replayTestHistory returns the history of a workflow of the type that
+// completed in its first task.
+func replayTestHistory(workflowType string) *historypb.History {
+	return &historypb.History{Events: []*historypb.HistoryEvent{
+		{
+			EventId:   1,
+			EventType: enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+			Attributes: &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+				WorkflowExecutionStartedEventAttributes: &historypb.WorkflowExecutionStartedEventAttributes{
+					WorkflowType: &common.WorkflowType{Name: workflowType},
+					TaskQueue:    &taskqueue.TaskQueue{Name: "tq"},
+				},
+			},
+		},
+		{
+			EventId:   2,
+			EventType: enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED,
+			Attributes: &historypb.HistoryEvent_WorkflowTaskScheduledEventAttributes{
+				WorkflowTaskScheduledEventAttributes: &historypb.WorkflowTaskScheduledEventAttributes{
+					TaskQueue: &taskqueue.TaskQueue{Name: "tq"},
+				},
+			},
+		},
+		{
+			EventId:   3,
+			EventType: enums.EVENT_TYPE_WORKFLOW_TASK_STARTED,
+			Attributes: &historypb.HistoryEvent_WorkflowTaskStartedEventAttributes{
+				WorkflowTaskStartedEventAttributes: &historypb.WorkflowTaskStartedEventAttributes{ScheduledEventId: 2},
+			},
+		},
+		{
+			EventId:   4,
+			EventType: enums.EVENT_TYPE_WORKFLOW_TASK_COMPLETED,
+			Attributes: &historypb.HistoryEvent_WorkflowTaskCompletedEventAttributes{
+				WorkflowTaskCompletedEventAttributes: &historypb.WorkflowTaskCompletedEventAttributes{
+					ScheduledEventId: 2,
+					StartedEventId:   3,
+				},
+			},
+		},
+		{
+			EventId:   5,
+			EventType: enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED,
+			Attributes: &historypb.HistoryEvent_WorkflowExecutionCompletedEventAttributes{
+				WorkflowExecutionCompletedEventAttributes: &historypb.WorkflowExecutionCompletedEventAttributes{
+					WorkflowTaskCompletedEventId: 4,
+				},
+			},
+		},
+	}}
+}
+
+// replayTimerTestWorkflow cannot replay the history of replayTestWorkflow.
+func replayTimerTestWorkflow(ctx workflow.Context) error { return workflow.Sleep(ctx, time.Minute) }
+
+func TestReplayHistoriesWithWorkerWorkflows(t *testing.T) {
+	workflowType := workflowTypeName(replayTestWorkflow)
+	sameType := func(fn interface{}) interface{} {
+		return WorkflowWithOptions{Workflow: fn, Options: workflow.RegisterOptions{Name: workflowType}}
+	}
+	onTaskQueue := func(taskQueue string) *historypb.History {
+		hist := replayTestHistory(workflowType)
+		hist.Events[0].GetWorkflowExecutionStartedEventAttributes().TaskQueue.Name = taskQueue
+		return hist
+	}
+	replay := func(feature *PreparedFeature, hist *historypb.History) error {
+		feature.Dir, feature.AbsDir = "test", t.TempDir()
+		return NewReplayRunner(RunnerConfig{}, feature).ReplayHistories(context.Background(), history.Histories{hist})
+	}
+	// A named worker on its own task queue replays its histories with its own
+	// implementation of the type
+	feature := func() *PreparedFeature {
+		return &PreparedFeature{
+			Workflows: []interface{}{replayTestWorkflow},
+			Workers: []*PreparedWorker{{
+				FeatureWorker: FeatureWorker{Name: "other", TaskQueueSuffix: "-other"},
+				Workflows:     []interface{}{sameType(replayTimerTestWorkflow)},
+			}},
+		}
+	}
+	assert.NoError(t, replay(feature(), onTaskQueue("tq")))
+	assert.ErrorContains(t, replay(feature(), onTaskQueue("tq-other")), "workflow "+workflowType)
+
+	// Of the builds on the feature's task queue, the one that completed the last
+	// workflow task replays it, regardless of which registered the type first
+	shared := &PreparedFeature{
+		Feature:   Feature{WorkerOptions: worker.Options{BuildID: "1.0"}},
+		Workflows: []interface{}{sameType(replayTimerTestWorkflow)},
+		Workers: []*PreparedWorker{{
+			FeatureWorker: FeatureWorker{Name: "v2", Options: worker.Options{BuildID: "2.0"}},
+			Workflows:     []interface{}{replayTestWorkflow},
+		}},
+	}
+	ranBy := func(buildID string) *historypb.History {
+		hist := onTaskQueue("tq")
+		hist.Events[3].GetWorkflowTaskCompletedEventAttributes().WorkerVersion = &common.WorkerVersionStamp{BuildId: buildID}
+		return hist
+	}
+	assert.NoError(t, replay(shared, ranBy("2.0")))
+	assert.ErrorContains(t, replay(shared, ranBy("1.0")), "nondeterministic")
+	assert.ErrorContains(t, replay(shared, ranBy("3.0")), `completed by build "3.0"`)
+	assert.ErrorContains(t, replay(shared, onTaskQueue("tq")), `completed by build ""`)
+}
+
+func TestReplayCrossLanguageHistories(t *testing.T) {
+	dir := t.TempDir()
+	store := func(lang, variant, version, workflowType string) {
+		storage := &history.Storage{Dir: filepath.Join(dir, "history"), Lang: lang, Variant: variant}
+		require.NoError(t, storage.Store(&history.StoredSet{ByVersion: map[string]history.Histories{
+			version: {replayTestHistory(workflowType)},
+		}}))
+	}
+	workflowType := workflowTypeName(replayTestWorkflow)
+	store("go", "", "v1.0.0", "Unknown")
+	store("java", "", "1.0.0", workflowType)
+	store("py", "", "1.0.0", "Unknown")
+	store("py", "enabled", "1.1.0", "Unknown")
+	store("ts", "", "1.0.0", "Unknown")
+
+	r := NewReplayRunner(RunnerConfig{HistoryCompare: history.CompareConfig{
+		Versions: map[string][]history.VersionPolicy{"ts": {{Stored: "<2.0.0", Check: history.VersionCheckIgnore}}},
+	}}, &PreparedFeature{
+		Dir:       "test",
+		AbsDir:    dir,
+		Workflows: []interface{}{replayTestWorkflow},
+	})
+	err := r.ReplayCrossLanguageHistories(context.Background())
+
+	// Go histories are not cross-language and ignored versions are not replayed,
+	// but every other failure is collected
+	var replayErr *CrossLanguageReplayError
+	require.True(t, errors.As(err, &replayErr), "unexpected error %v", err)
+	require.Len(t, replayErr.Failures, 2)
+	for i, want := range []CrossLanguageReplayFailure{
+		{Lang: "py", Version: "1.0.0"},
+		{Lang: "py", Variant: "enabled", Version: "1.1.0"},
+	} {
+		failure := replayErr.Failures[i]
+		assert.Equal(t, want.Lang, failure.Lang)
+		assert.Equal(t, want.Variant, failure.Variant)
+		assert.Equal(t, want.Version, failure.Version)
+		assert.ErrorContains(t, failure.Err, "workflow Unknown")
+	}
+	assert.Contains(t, err.Error(), "cross-language replay failed for 2 history file(s)")
+	assert.Contains(t, err.Error(), "\n  py 1.0.0: ")
+	assert.Contains(t, err.Error(), "\n  py 1.1.0 of variant enabled: ")
+
+	// Nothing failing is not an error
+	store("py", "", "1.0.0", workflowType)
+	store("py", "enabled", "1.1.0", workflowType)
+	assert.NoError(t, r.ReplayCrossLanguageHistories(context.Background()))
+}
+
+func TestStoredHistoryFilesSkipsLaterAndIgnoredVersions(t *testing.T) {
+	dir := t.TempDir()
+	for _, version := range []string{"v1.0.0", "v1.1.0", SDKVersion, "v999.0.0"} {
+		storage := &history.Storage{Dir: filepath.Join(dir, "history"), Lang: "go"}
+		require.NoError(t, storage.Store(&history.StoredSet{ByVersion: map[string]history.Histories{
+			version: {replayTestHistory("Workflow")},
+		}}))
+	}
+	r := NewReplayRunner(RunnerConfig{HistoryCompare: history.CompareConfig{
+		Versions: map[string][]history.VersionPolicy{"go": {{Stored: "<1.1.0", Check: history.VersionCheckIgnore}}},
+	}}, &PreparedFeature{Dir: "test", AbsDir: dir})
+	files, err := r.StoredHistoryFiles()
+	require.NoError(t, err)
+	var versions []string
+	for _, file := range files {
+		versions = append(versions, file.Version)
+	}
+	assert.ElementsMatch(t, []string{"v1.1.0", SDKVersion}, versions)
+}
+
+func TestScrubHistoriesAppliesFeatureRules(t *testing.T) {
+	newHist := func(input string) history.Histories {
+		hist := replayTestHistory("Workflow")
+		payloads, err := converter.GetDefaultDataConverter().ToPayloads(input)
+		require.NoError(t, err)
+		hist.Events[0].GetWorkflowExecutionStartedEventAttributes().Input = payloads
+		return history.Histories{hist}
+	}
+
+	// Run-specific fields alone do not cover the input
+	runner := NewReplayRunner(RunnerConfig{}, &PreparedFeature{})
+	a, b := newHist("a"), newHist("b")
+	require.NoError(t, runner.ScrubHistories(a))
+	require.NoError(t, runner.ScrubHistories(b))
+	assert.False(t, a.Equals(b))
+
+	runner = NewReplayRunner(RunnerConfig{HistoryCompare: history.CompareConfig{
+		Scrub: []history.ScrubRule{{EventType: "WorkflowExecutionStarted", Path: "input"}},
+	}}, &PreparedFeature{})
+	a, b = newHist("a"), newHist("b")
+	require.NoError(t, runner.ScrubHistories(a))
+	require.NoError(t, runner.ScrubHistories(b))
+	assert.True(t, a.Equals(b))
+	// Run-specific fields are scrubbed too
+	assert.Empty(t, a[0].Events[0].GetWorkflowExecutionStartedEventAttributes().GetTaskQueue().GetName())
+}
+
+func TestTeardownSkippedWithoutClient(t *testing.T) {
+	teardownCalled := false
+	feature, err := PrepareFeature(Feature{
+		SkipReason: "test",
+		Setup:      func(context.Context, *Runner) error { return errors.New("setup failed") },
+		Teardown:   func(context.Context, *Runner) { teardownCalled = true },
+	})
+	require.NoError(t, err)
+	_, err = NewRunner(context.Background(), RunnerConfig{}, feature)
+	require.ErrorContains(t, err, "setup failed")
+	assert.False(t, teardownCalled)
+}
+
+func TestTeardownContextOutlivesRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var teardownErr error
+	var teardownDeadline time.Time
+	var teardownClient client.Client
+	feature, err := PrepareFeature(Feature{
+		SkipReason: "test",
+		Teardown: func(ctx context.Context, r *Runner) {
+			teardownClient = r.Client
+			teardownErr = ctx.Err()
+			teardownDeadline, _ = ctx.Deadline()
+		},
+	})
+	require.NoError(t, err)
+	// A runner as NewRunner leaves it once the client is created
+	cl, err := client.NewLazyClient(client.Options{})
+	require.NoError(t, err)
+	r := &Runner{
+		RunnerConfig:    RunnerConfig{Log: DefaultLogger},
+		Feature:         feature,
+		Client:          cl,
+		teardownPending: true,
+		teardownCtx:     context.WithoutCancel(ctx),
+	}
+	r.Close()
+
+	// Teardown is called with the client and a context that is bounded but not
+	// canceled with the run
+	require.NotNil(t, teardownClient)
+	assert.NoError(t, teardownErr)
+	assert.WithinDuration(t, time.Now().Add(TeardownTimeout), teardownDeadline, 10*time.Second)
+	assert.Nil(t, r.Client)
+}
+
+func TestCloseClosesClientWhenTeardownPanics(t *testing.T) {
+	feature, err := PrepareFeature(Feature{
+		SkipReason: "test",
+		Teardown:   func(context.Context, *Runner) { panic("teardown failed") },
+	})
+	require.NoError(t, err)
+	cl, err := client.NewLazyClient(client.Options{})
+	require.NoError(t, err)
+	r := &Runner{
+		RunnerConfig:    RunnerConfig{Log: DefaultLogger},
+		Feature:         feature,
+		Client:          cl,
+		teardownPending: true,
+		teardownCtx:     context.Background(),
+	}
+	assert.PanicsWithValue(t, "teardown failed", r.Close)
+	assert.Nil(t, r.Client)
+}
+
+// testWorkflowRun is a run that completed with the result, or with no result if
+// it is nil.
+type testWorkflowRun struct {
+	client.WorkflowRun
+	result interface{}
+}
+
+func (r testWorkflowRun) Get(_ context.Context, valuePtr interface{}) error {
+	if valuePtr == nil || r.result == nil {
+		return nil
+	}
+	payloads, err := converter.GetDefaultDataConverter().ToPayloads(r.result)
+	if err != nil {
+		return err
+	}
+	return converter.GetDefaultDataConverter().FromPayloads(payloads, valuePtr)
+}
+
+func TestCheckResultDefault(t *testing.T) {
+	for _, tt := range []struct {
+		expected interface{}
+		result   interface{}
+		pass     bool
+	}{
+		{expected: nil, result: "anything", pass: true},
+		{expected: "result", result: "result", pass: true},
+		{expected: "result", result: "other", pass: false},
+		{expected: NilRunResult, result: nil, pass: true},
+		{expected: NilRunResult, result: "result", pass: false},
+	} {
+		r := &Runner{Feature: &PreparedFeature{Feature: Feature{ExpectRunResult: tt.expected}}}
+		r.SoftAssert = assert.New(assertTestingFunc(func(format string, args ...interface{}) {
+			r.LastAssertErr = fmt.Errorf(format, args...)
+		}))
+		err := r.CheckResultDefault(context.Background(), testWorkflowRun{result: tt.result})
+		assert.Equal(t, tt.pass, err == nil, "expected %v, result %v, error %v", tt.expected, tt.result, err)
+	}
+}
+
+func caseTestWorkflow(_ workflow.Context, input string) (string, error) { return input, nil }
+
+// caseTestClient starts no workflows, its runs complete with the first
+// argument they were started with.
+type caseTestClient struct {
+	client.Client
+	args []interface{}
+}
+
+func (c *caseTestClient) ExecuteWorkflow(
+	_ context.Context,
+	_ client.StartWorkflowOptions,
+	_ interface{},
+	args ...interface{},
+) (client.WorkflowRun, error) {
+	c.args = args
+	return testWorkflowRun{result: args[0]}, nil
+}
+
+func TestRunFeatureCases(t *testing.T) {
+	feature, err := PrepareFeature(Feature{
+		SkipReason: "test",
+		Workflows:  []interface{}{caseTestWorkflow},
+		Cases: []FeatureCase{
+			{Name: "value", Args: []interface{}{"value"}, ExpectRunResult: "value"},
+			{Name: "empty", Args: []interface{}{""}, ExpectRunResult: ""},
+			{Name: "mismatch", Args: []interface{}{"value"}, ExpectRunResult: "other"},
+		},
+		StartWorkflowOptionsMutator: func(*client.StartWorkflowOptions) {},
+	})
+	require.NoError(t, err)
+	for _, featureCase := range feature.Cases {
+		caseFeature, err := feature.WithCase(featureCase.Name)
+		require.NoError(t, err)
+		cl := &caseTestClient{}
+		r := &Runner{Feature: caseFeature, Client: cl}
+		r.SoftAssert = assert.New(assertTestingFunc(func(format string, args ...interface{}) {
+			r.LastAssertErr = fmt.Errorf(format, args...)
+		}))
+
+		// Each case starts the workflow with its own args and checks its own
+		// expected result
+		run, err := r.ExecuteDefault(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, featureCase.Args, cl.args)
+		err = r.CheckResultDefault(context.Background(), run)
+		assert.Equal(t, featureCase.Name != "mismatch", err == nil, "case %v error %v", featureCase.Name, err)
+	}
+}
@@ -48,7 +48,7 @@ func ExecuteWithArgs(workflow interface{}, args ...interface{}) func(ctx context
 	return func(ctx context.Context, r *Runner) (client.WorkflowRun, error) {
 		opts := client.StartWorkflowOptions{
 			TaskQueue:                r.TaskQueue,
-			WorkflowExecutionTimeout: 1 * time.Minute,
+			WorkflowExecutionTimeout: DefaultWorkflowExecutionTimeout,
 		}
 		r.Feature.StartWorkflowOptionsMutator(&opts)
 		return r.Client.ExecuteWorkflow(ctx, opts, workflow, args...)
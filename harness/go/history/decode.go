@@ -0,0 +1,106 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// payloadName is the full name of the payload message decoded by a
+// PayloadDecoder.
+var payloadName = (&common.Payload{}).ProtoReflect().Descriptor().FullName()
+
+// PayloadDecoder decodes a payload into a readable value. Decoding failures
+// are returned as the value so differences still show something.
+type PayloadDecoder func(*common.Payload) string
+
+// DataConverterDecoder returns a decoder that uses the data converter's
+// ToString, which for the default converter is the JSON of JSON payloads.
+func DataConverterDecoder(dc converter.DataConverter) PayloadDecoder {
+	return dc.ToString
+}
+
+// CodecDecoder returns a decoder that decodes payloads with the codec, then
+// uses the default data converter.
+func CodecDecoder(codec converter.PayloadCodec) PayloadDecoder {
+	return DataConverterDecoder(converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), codec))
+}
+
+// DecodedJSON returns the histories as indented JSON with every payload
+// replaced by an object with its decoded value as "decoded", instead of the
+// payload's base64 metadata and data.
+func (h Histories) DecodedJSON(decode PayloadDecoder) ([]byte, error) {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	if v, err = decodeJSONPayloads(v, decode); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// decodeJSONPayloads replaces every object in the unmarshaled JSON that is a
+// payload with its decoded value.
+func decodeJSONPayloads(v any, decode PayloadDecoder) (any, error) {
+	var err error
+	switch v := v.(type) {
+	case map[string]any:
+		if isJSONPayload(v) {
+			b, _ := json.Marshal(v)
+			var payload common.Payload
+			if err := protojson.Unmarshal(b, &payload); err != nil {
+				return nil, fmt.Errorf("failed unmarshaling payload: %w", err)
+			}
+			return map[string]any{"decoded": decode(&payload)}, nil
+		}
+		for key, value := range v {
+			if v[key], err = decodeJSONPayloads(value, decode); err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, value := range v {
+			if v[i], err = decodeJSONPayloads(value, decode); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// isJSONPayload returns whether the JSON object is a payload, which has an
+// encoding in its metadata and no fields other than a payload's.
+func isJSONPayload(v map[string]any) bool {
+	metadata, ok := v["metadata"].(map[string]any)
+	if !ok || metadata["encoding"] == nil {
+		return false
+	}
+	for key := range v {
+		if key != "metadata" && key != "data" && key != "externalPayloads" {
+			return false
+		}
+	}
+	return true
+}
+
+// decodedPayload returns the decoded value of the message if it is a payload
+// and there is a decoder.
+func (o DiffOptions) decodedPayload(msg protoreflect.Message) (string, bool) {
+	if o.DecodePayload == nil || msg.Descriptor().FullName() != payloadName {
+		return "", false
+	}
+	payload, ok := msg.Interface().(*common.Payload)
+	if !ok {
+		return "", false
+	}
+	return o.DecodePayload(payload), true
+}
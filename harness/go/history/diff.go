@@ -0,0 +1,421 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/history/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxDiffValueLen is the length values are truncated to in differences.
+const maxDiffValueLen = 120
+
+const eventIDField protoreflect.FullName = "temporal.api.history.v1.HistoryEvent.event_id"
+
+// Difference is a single difference between an expected and an actual history.
+type Difference struct {
+	// Workflow type of the history the difference is in
+	Workflow string `json:"workflow"`
+	// Name of the execution the difference is in, see Histories.Executions
+	Execution string `json:"execution,omitempty"`
+	// Event ID the difference is at, or 0 if the whole history differs. This is
+	// the expected event's ID unless there is no expected event.
+	EventID int64 `json:"eventId,omitempty"`
+	// Path of the differing attribute, or empty if the events themselves differ
+	Attribute string `json:"attribute,omitempty"`
+	// Description of the expected value, or empty if none was expected
+	Expected string `json:"expected,omitempty"`
+	// Description of the actual value, or empty if none was present
+	Actual string `json:"actual,omitempty"`
+}
+
+// Differences is a list of differences between histories.
+type Differences []Difference
+
+// DiffOptions are options for computing differences between histories.
+type DiffOptions struct {
+	// DecodePayload, if set, decodes payloads so a differing payload is reported
+	// once with its decoded values instead of as its encoded metadata and data.
+	DecodePayload PayloadDecoder
+}
+
+// String returns a single line describing the difference.
+func (d Difference) String() string {
+	var b strings.Builder
+	b.WriteString("workflow " + d.Workflow)
+	if d.Execution != "" && d.Execution != d.Workflow {
+		b.WriteString(" (execution " + d.Execution + ")")
+	}
+	switch {
+	case d.EventID == 0 && d.Expected == "":
+		b.WriteString(": unexpected history")
+		return b.String()
+	case d.EventID == 0:
+		b.WriteString(": expected history, got nothing")
+		return b.String()
+	}
+	fmt.Fprintf(&b, " event %v", d.EventID)
+	if d.Attribute != "" {
+		fmt.Fprintf(&b, " attribute %v differs: expected %v, got %v", d.Attribute, d.Expected, d.Actual)
+	} else if d.Expected == "" {
+		fmt.Fprintf(&b, ": unexpected %v", d.Actual)
+	} else if d.Actual == "" {
+		fmt.Fprintf(&b, ": expected %v, got nothing", d.Expected)
+	} else {
+		fmt.Fprintf(&b, ": expected %v, got %v", d.Expected, d.Actual)
+	}
+	return b.String()
+}
+
+// String returns each difference on its own line.
+func (d Differences) String() string {
+	lines := make([]string, len(d))
+	for i, diff := range d {
+		lines[i] = diff.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// JSON returns the differences as an indented JSON array.
+func (d Differences) JSON() (string, error) {
+	if d == nil {
+		d = Differences{}
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed marshaling differences: %w", err)
+	}
+	return string(b), nil
+}
+
+// Diff returns the differences between the expected and actual histories.
+// Histories are paired by their names from Histories.Executions. Both sets of
+// histories should already be scrubbed, so this effectively pairs them by
+// workflow type in order. Use DiffExecutions to pair histories by names taken
+// before scrubbing.
+func Diff(expected, actual Histories) Differences {
+	return DiffExecutions(expected, expected.Executions(), actual, actual.Executions())
+}
+
+// DiffExecutions returns the differences between the expected and actual
+// histories with default options. See DiffOptions.DiffExecutions.
+func DiffExecutions(expected Histories, expectedNames []string, actual Histories, actualNames []string) Differences {
+	return DiffOptions{}.DiffExecutions(expected, expectedNames, actual, actualNames)
+}
+
+// DiffExecutions returns the differences between the expected and actual
+// histories, pairing histories with the same execution name. The names are
+// from Histories.Executions and are in the same order as the histories. Events
+// within each pair are aligned by event type so an extra or missing event is
+// reported once instead of making every later event differ. Aligned events are
+// compared attribute by attribute. Both sets of histories should already be
+// scrubbed.
+func (o DiffOptions) DiffExecutions(expected Histories, expectedNames []string, actual Histories, actualNames []string) Differences {
+	var diffs Differences
+	actualByName := make(map[string]*history.History, len(actual))
+	for i, hist := range actual {
+		actualByName[actualNames[i]] = hist
+	}
+	matched := map[string]bool{}
+	for i, expectedHist := range expected {
+		workflow, _ := historyFirstEventName(expectedHist)
+		name := expectedNames[i]
+		actualHist := actualByName[name]
+		if actualHist == nil {
+			diffs = append(diffs, Difference{Workflow: workflow, Execution: name, Expected: "history"})
+			continue
+		}
+		matched[name] = true
+		for _, diff := range o.diffEvents(workflow, expectedHist.Events, actualHist.Events) {
+			diff.Execution = name
+			diffs = append(diffs, diff)
+		}
+	}
+	for i, hist := range actual {
+		if !matched[actualNames[i]] {
+			workflow, _ := historyFirstEventName(hist)
+			diffs = append(diffs, Difference{Workflow: workflow, Execution: actualNames[i], Actual: "history"})
+		}
+	}
+	return diffs
+}
+
+// eventKey is a cheap stand-in for an event when aligning events. Events with
+// the same key are the same apart from their IDs, barring hash collisions,
+// which only affect how events are aligned and not the differences reported.
+type eventKey struct {
+	eventType enums.EventType
+	hash      uint64
+}
+
+// eventKeys returns the key of each event.
+func eventKeys(events []*history.HistoryEvent) []eventKey {
+	keys := make([]eventKey, len(events))
+	marshal := proto.MarshalOptions{Deterministic: true}
+	for i, event := range events {
+		noID := proto.Clone(event).(*history.HistoryEvent)
+		noID.EventId = 0
+		h := fnv.New64a()
+		// Failing to marshal only leaves the hash of the type, so the event is
+		// still aligned by type
+		b, _ := marshal.Marshal(noID)
+		_, _ = h.Write(b)
+		keys[i] = eventKey{eventType: event.EventType, hash: h.Sum64()}
+	}
+	return keys
+}
+
+func (o DiffOptions) diffEvents(workflow string, expected, actual []*history.HistoryEvent) Differences {
+	// Align with a longest common subsequence of event types, weighted so that
+	// identical events are preferred over events that only share a type. Only
+	// the keys are compared while aligning, aligned events are compared in full
+	// afterwards.
+	expectedKeys, actualKeys := eventKeys(expected), eventKeys(actual)
+	score := func(i, j int) int {
+		if expectedKeys[i] == actualKeys[j] {
+			return 2
+		} else if expectedKeys[i].eventType == actualKeys[j].eventType {
+			return 1
+		}
+		return 0
+	}
+	// Identical leading and trailing events are always aligned, so only the
+	// events between them need the quadratic table
+	prefix := 0
+	for prefix < len(expected) && prefix < len(actual) && score(prefix, prefix) == 2 {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(expected)-prefix && suffix < len(actual)-prefix &&
+		score(len(expected)-1-suffix, len(actual)-1-suffix) == 2 {
+		suffix++
+	}
+	expectedEnd, actualEnd := len(expected)-suffix, len(actual)-suffix
+	lcs := make([][]int, expectedEnd-prefix+1)
+	for i := range lcs {
+		lcs[i] = make([]int, actualEnd-prefix+1)
+	}
+	// Table indices are offset by the prefix
+	at := func(i, j int) int { return lcs[i-prefix][j-prefix] }
+	for i := expectedEnd - 1; i >= prefix; i-- {
+		for j := actualEnd - 1; j >= prefix; j-- {
+			best := max(at(i+1, j), at(i, j+1))
+			if matchScore := score(i, j); matchScore > 0 {
+				best = max(best, at(i+1, j+1)+matchScore)
+			}
+			lcs[i-prefix][j-prefix] = best
+		}
+	}
+
+	// Walk the alignment, pairing up unaligned runs of events as substitutions
+	var diffs Differences
+	var missing, extra []*history.HistoryEvent
+	flush := func() {
+		for k := 0; k < len(missing) || k < len(extra); k++ {
+			diff := Difference{Workflow: workflow}
+			if k < len(missing) {
+				diff.EventID = missing[k].EventId
+				diff.Expected = describeEvent(missing[k])
+			}
+			if k < len(extra) {
+				if diff.EventID == 0 {
+					diff.EventID = extra[k].EventId
+				}
+				diff.Actual = describeEvent(extra[k])
+			}
+			diffs = append(diffs, diff)
+		}
+		missing, extra = nil, nil
+	}
+	align := func(i, j int) {
+		flush()
+		for _, attr := range o.diffMessages("", expected[i].ProtoReflect(), actual[j].ProtoReflect()) {
+			attr.Workflow, attr.EventID = workflow, expected[i].EventId
+			diffs = append(diffs, attr)
+		}
+	}
+	for k := 0; k < prefix; k++ {
+		align(k, k)
+	}
+	i, j := prefix, prefix
+	for i < expectedEnd || j < actualEnd {
+		switch {
+		case i < expectedEnd && j < actualEnd && score(i, j) > 0 && at(i, j) == at(i+1, j+1)+score(i, j):
+			align(i, j)
+			i++
+			j++
+		case j == actualEnd || (i < expectedEnd && at(i+1, j) >= at(i, j+1)):
+			missing = append(missing, expected[i])
+			i++
+		default:
+			extra = append(extra, actual[j])
+			j++
+		}
+	}
+	flush()
+	for k := 0; k < suffix; k++ {
+		align(expectedEnd+k, actualEnd+k)
+	}
+	return diffs
+}
+
+// diffMessages returns a difference for each differing leaf field path of the
+// two messages of the same type. Attribute oneof wrappers are left out of the
+// path so paths are relative to the event's attributes. Payloads are compared
+// by decoded value if there is a decoder.
+func (o DiffOptions) diffMessages(prefix string, expected, actual protoreflect.Message) Differences {
+	if expectedDecoded, ok := o.decodedPayload(expected); ok {
+		if proto.Equal(expected.Interface(), actual.Interface()) {
+			return nil
+		}
+		actualDecoded, _ := o.decodedPayload(actual)
+		if expectedDecoded == actualDecoded {
+			actualDecoded += " (encoded differently)"
+		}
+		return Differences{{Attribute: prefix, Expected: truncateValue(expectedDecoded), Actual: truncateValue(actualDecoded)}}
+	}
+	var diffs Differences
+	fields := expected.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		expectedHas, actualHas := expected.Has(field), actual.Has(field)
+		// Event IDs are positional and already covered by the alignment
+		if (!expectedHas && !actualHas) || field.FullName() == eventIDField {
+			continue
+		}
+		expectedValue, actualValue := expected.Get(field), actual.Get(field)
+		path := prefix
+		if oneof := field.ContainingOneof(); oneof == nil || oneof.Name() != "attributes" {
+			path = joinPath(prefix, field.JSONName())
+		}
+		switch {
+		case field.IsList():
+			expectedList, actualList := expectedValue.List(), actualValue.List()
+			if expectedList.Len() != actualList.Len() || field.Message() == nil {
+				if !expectedValue.Equal(actualValue) {
+					diffs = append(diffs, Difference{
+						Attribute: path,
+						Expected:  o.describeValue(field, expectedValue, expectedHas),
+						Actual:    o.describeValue(field, actualValue, actualHas),
+					})
+				}
+				continue
+			}
+			for k := 0; k < expectedList.Len(); k++ {
+				diffs = append(diffs, o.diffMessages(fmt.Sprintf("%v[%v]", path, k),
+					expectedList.Get(k).Message(), actualList.Get(k).Message())...)
+			}
+		case field.Message() != nil && !field.IsMap() && expectedHas && actualHas:
+			diffs = append(diffs, o.diffMessages(path, expectedValue.Message(), actualValue.Message())...)
+		case expectedHas != actualHas || !expectedValue.Equal(actualValue):
+			diffs = append(diffs, Difference{
+				Attribute: path,
+				Expected:  o.describeValue(field, expectedValue, expectedHas),
+				Actual:    o.describeValue(field, actualValue, actualHas),
+			})
+		}
+	}
+	return diffs
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// describeValue returns a short form of the field's value for a difference.
+func (o DiffOptions) describeValue(field protoreflect.FieldDescriptor, value protoreflect.Value, has bool) string {
+	if !has {
+		return "<unset>"
+	}
+	var s string
+	switch {
+	case field.IsList():
+		list := value.List()
+		parts := make([]string, list.Len())
+		for i := range parts {
+			parts[i] = o.describeSingleValue(field, list.Get(i))
+		}
+		s = "[" + strings.Join(parts, ",") + "]"
+	case field.IsMap():
+		var parts []string
+		value.Map().Range(func(key protoreflect.MapKey, v protoreflect.Value) bool {
+			parts = append(parts, fmt.Sprintf("%q:%v", key.String(), o.describeSingleValue(field.MapValue(), v)))
+			return true
+		})
+		sort.Strings(parts)
+		s = "{" + strings.Join(parts, ",") + "}"
+	default:
+		s = o.describeSingleValue(field, value)
+	}
+	return truncateValue(s)
+}
+
+// truncateValue truncates a described value to maxDiffValueLen.
+func truncateValue(s string) string {
+	if len(s) > maxDiffValueLen {
+		s = s[:maxDiffValueLen] + "..."
+	}
+	return s
+}
+
+func (o DiffOptions) describeSingleValue(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if decoded, ok := o.decodedPayload(value.Message()); ok {
+			return decoded
+		} else if b, err := protojson.Marshal(value.Message().Interface()); err == nil {
+			return string(b)
+		}
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByNumber(value.Enum()); enumValue != nil {
+			return string(enumValue.Name())
+		}
+	case protoreflect.StringKind:
+		return fmt.Sprintf("%q", value.String())
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("%q", value.Bytes())
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// describeEvent returns the event type with the attribute that best identifies
+// the event, e.g. ActivityTaskScheduled(activityType=Foo).
+func describeEvent(event *history.HistoryEvent) string {
+	name := event.EventType.String()
+	var detail string
+	switch attrs := event.Attributes.(type) {
+	case *history.HistoryEvent_WorkflowExecutionStartedEventAttributes:
+		detail = "workflowType=" + attrs.WorkflowExecutionStartedEventAttributes.GetWorkflowType().GetName()
+	case *history.HistoryEvent_ActivityTaskScheduledEventAttributes:
+		detail = "activityType=" + attrs.ActivityTaskScheduledEventAttributes.GetActivityType().GetName()
+	case *history.HistoryEvent_TimerStartedEventAttributes:
+		detail = "timerId=" + attrs.TimerStartedEventAttributes.GetTimerId()
+	case *history.HistoryEvent_MarkerRecordedEventAttributes:
+		detail = "markerName=" + attrs.MarkerRecordedEventAttributes.GetMarkerName()
+	case *history.HistoryEvent_WorkflowExecutionSignaledEventAttributes:
+		detail = "signalName=" + attrs.WorkflowExecutionSignaledEventAttributes.GetSignalName()
+	case *history.HistoryEvent_SignalExternalWorkflowExecutionInitiatedEventAttributes:
+		detail = "signalName=" + attrs.SignalExternalWorkflowExecutionInitiatedEventAttributes.GetSignalName()
+	case *history.HistoryEvent_StartChildWorkflowExecutionInitiatedEventAttributes:
+		detail = "workflowType=" + attrs.StartChildWorkflowExecutionInitiatedEventAttributes.GetWorkflowType().GetName()
+	case *history.HistoryEvent_WorkflowExecutionUpdateAcceptedEventAttributes:
+		detail = "updateName=" + attrs.WorkflowExecutionUpdateAcceptedEventAttributes.GetAcceptedRequest().GetInput().GetName()
+	case *history.HistoryEvent_NexusOperationScheduledEventAttributes:
+		detail = "operation=" + attrs.NexusOperationScheduledEventAttributes.GetService() + "/" +
+			attrs.NexusOperationScheduledEventAttributes.GetOperation()
+	}
+	if detail == "" {
+		return name
+	}
+	return name + "(" + detail + ")"
+}
@@ -0,0 +1,100 @@
+package history
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+func TestDiffEqual(t *testing.T) {
+	hists := Histories{testHistory("Workflow", testActivityScheduled("Foo", "a"), testTimerStarted("1"))}
+	assert.Empty(t, Diff(hists, hists.Clone()))
+}
+
+func TestDiffAlignsEvents(t *testing.T) {
+	expected := Histories{testHistory("Workflow",
+		testActivityScheduled("Foo", "a"), testTimerStarted("1"), testActivityScheduled("Bar", "b"))}
+	// First activity replaced by a timer, second activity input changed
+	actual := Histories{testHistory("Workflow",
+		testTimerStarted("0"), testTimerStarted("1"), testActivityScheduled("Bar", "c"))}
+	diffs := Diff(expected, actual)
+	require.Len(t, diffs, 2, diffs.String())
+	assert.Equal(t, "workflow Workflow event 2: expected ActivityTaskScheduled(activityType=Foo), got TimerStarted(timerId=0)",
+		diffs[0].String())
+	assert.Equal(t, `workflow Workflow event 4 attribute input.payloads[0].data differs: expected "b", got "c"`,
+		diffs[1].String())
+
+	// An extra event shifts event IDs but only reports the extra event
+	actual = Histories{testHistory("Workflow",
+		testActivityScheduled("Foo", "a"), testTimerStarted("1"), testTimerStarted("2"), testActivityScheduled("Bar", "b"))}
+	diffs = Diff(expected, actual)
+	require.Len(t, diffs, 1, diffs.String())
+	assert.Equal(t, "workflow Workflow event 4: unexpected TimerStarted(timerId=2)", diffs[0].String())
+}
+
+func TestDiffAlignsLongHistories(t *testing.T) {
+	var expectedEvents, actualEvents []*history.HistoryEvent
+	for i := 0; i < 3000; i++ {
+		expectedEvents = append(expectedEvents, testTimerStarted(strconv.Itoa(i)))
+		actualEvents = append(actualEvents, testTimerStarted(strconv.Itoa(i)))
+	}
+	// A changed event in the middle and an extra one near the end
+	actualEvents[1500] = testTimerStarted("changed")
+	actualEvents = append(actualEvents[:2500], append([]*history.HistoryEvent{testActivityScheduled("Foo", "a")},
+		actualEvents[2500:]...)...)
+	diffs := Diff(Histories{testHistory("Workflow", expectedEvents...)}, Histories{testHistory("Workflow", actualEvents...)})
+	require.Len(t, diffs, 2, diffs.String())
+	assert.Equal(t, `workflow Workflow event 1502 attribute timerId differs: expected "1500", got "changed"`, diffs[0].String())
+	assert.Equal(t, "workflow Workflow event 2502: unexpected ActivityTaskScheduled(activityType=Foo)", diffs[1].String())
+}
+
+func TestDiffHistoriesAndJSON(t *testing.T) {
+	expected := Histories{testHistory("A"), testHistory("B", testTimerStarted("1"))}
+	actual := Histories{testHistory("B"), testHistory("C")}
+	diffs := Diff(expected, actual)
+	assert.Equal(t, "workflow A: expected history, got nothing\n"+
+		"workflow B event 2: expected TimerStarted(timerId=1), got nothing\n"+
+		"workflow C: unexpected history", diffs.String())
+
+	s, err := diffs.JSON()
+	require.NoError(t, err)
+	var decoded Differences
+	require.NoError(t, json.Unmarshal([]byte(s), &decoded))
+	assert.Equal(t, diffs, decoded)
+}
+
+func TestDiffDecodesPayloads(t *testing.T) {
+	codec := converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), converter.NewZlibCodec(
+		converter.ZlibCodecOptions{AlwaysEncode: true}))
+	activity := func(value string) *history.HistoryEvent {
+		payload, err := codec.ToPayload(value)
+		require.NoError(t, err)
+		event := testActivityScheduled("Foo", "")
+		event.GetActivityTaskScheduledEventAttributes().Input.Payloads[0] = payload
+		return event
+	}
+	expected := Histories{testHistory("Workflow", activity("hello"))}
+	actual := Histories{testHistory("Workflow", activity("world"))}
+
+	// Without a decoder the encoded bytes differ
+	diffs := Diff(expected, actual)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, "input.payloads[0].data", diffs[0].Attribute)
+
+	opts := DiffOptions{DecodePayload: CodecDecoder(converter.NewZlibCodec(converter.ZlibCodecOptions{}))}
+	diffs = opts.DiffExecutions(expected, expected.Executions(), actual, actual.Executions())
+	require.Len(t, diffs, 1)
+	assert.Equal(t, `workflow Workflow event 2 attribute input.payloads[0] differs: expected "hello", got "world"`,
+		diffs[0].String())
+
+	b, err := actual.DecodedJSON(opts.DecodePayload)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"decoded": "\"world\""`)
+	assert.NotContains(t, string(b), `"data"`)
+}
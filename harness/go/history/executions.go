@@ -0,0 +1,205 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/history/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Executions returns a name for each history, in the same order, that describes
+// where the execution is in the structure of the set instead of run-specific
+// identifiers. The relationships are taken from history attributes, so this
+// must be called before scrubbing. Names are:
+//
+//   - The workflow type for executions with no parent or predecessor, suffixed
+//     with "#2", "#3", etc. for later executions of the same type.
+//   - The parent's name, "/", the child's workflow type, "@", and the parent
+//     event ID that initiated the child, for child workflows.
+//   - The predecessor's name suffixed with "+continued", "+retry", or "+cron"
+//     for executions continued from another one.
+//
+// For example a retry of a child started by the fifth event of the second
+// execution of MyWorkflow is named "MyWorkflow#2/MyChild@5+retry". If the
+// relationships cannot be determined, such as on already scrubbed histories,
+// every execution is named as if it had no parent or predecessor.
+func (h Histories) Executions() []string {
+	return h.executions(true)
+}
+
+// PairExecutions returns the execution names of both sets of histories, like
+// Histories.Executions, for pairing them with EqualExecutions or
+// DiffExecutions. It must be called before scrubbing. If either set has no
+// relationships, such as a stored set that was scrubbed before it was written,
+// both are named as if no execution had a parent or predecessor, which pairs
+// executions by workflow type in order.
+func PairExecutions(a, b Histories) (aNames, bNames []string) {
+	relationships := a.hasRelationships() && b.hasRelationships()
+	return a.executions(relationships), b.executions(relationships)
+}
+
+// hasRelationships returns whether any execution has the workflow ID that its
+// relationships are found by, which scrubbing removes.
+func (h Histories) hasRelationships() bool {
+	for _, hist := range h {
+		if startedAttributes(hist).GetWorkflowId() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Histories) executions(relationships bool) []string {
+	names := make([]string, len(h))
+	resolving := make([]bool, len(h))
+	rootCounts := map[string]int{}
+	var name func(i int) string
+	name = func(i int) string {
+		if names[i] != "" {
+			return names[i]
+		}
+		workflowType, _ := historyFirstEventName(h[i])
+		attrs := startedAttributes(h[i])
+		// Guard against cycles in malformed sets by treating them as roots
+		resolving[i] = true
+		defer func() { resolving[i] = false }()
+		pred, parent := -1, -1
+		if relationships {
+			pred, parent = h.predecessor(i), h.parent(i)
+		}
+		if pred >= 0 && !resolving[pred] {
+			switch attrs.GetInitiator() {
+			case enums.CONTINUE_AS_NEW_INITIATOR_RETRY:
+				names[i] = name(pred) + "+retry"
+			case enums.CONTINUE_AS_NEW_INITIATOR_CRON_SCHEDULE:
+				names[i] = name(pred) + "+cron"
+			default:
+				names[i] = name(pred) + "+continued"
+			}
+		} else if parent >= 0 && !resolving[parent] {
+			names[i] = name(parent) + "/" + workflowType + "@" + strconv.FormatInt(attrs.GetParentInitiatedEventId(), 10)
+		} else {
+			rootCounts[workflowType]++
+			names[i] = workflowType
+			if count := rootCounts[workflowType]; count > 1 {
+				names[i] += "#" + strconv.Itoa(count)
+			}
+		}
+		return names[i]
+	}
+	// Resolve in order, then make sure names are unique in case relationships
+	// could only be partially determined
+	seen := map[string]int{}
+	for i := range h {
+		name(i)
+	}
+	for i, n := range names {
+		if seen[n]++; seen[n] > 1 {
+			names[i] = fmt.Sprintf("%v#%v", n, seen[n])
+		}
+	}
+	return names
+}
+
+// predecessor returns the index of the execution the one at i continued from,
+// or -1 if none.
+func (h Histories) predecessor(i int) int {
+	attrs := startedAttributes(h[i])
+	if attrs.GetContinuedExecutionRunId() == "" || attrs.GetWorkflowId() == "" {
+		return -1
+	}
+	fallback := -1
+	for j, other := range h {
+		otherAttrs := startedAttributes(other)
+		if j == i || otherAttrs.GetWorkflowId() != attrs.GetWorkflowId() {
+			continue
+		} else if otherAttrs.GetOriginalExecutionRunId() == attrs.GetContinuedExecutionRunId() {
+			return j
+		} else if attrs.GetOriginalExecutionRunId() != "" &&
+			newExecutionRunID(other) == attrs.GetOriginalExecutionRunId() && fallback < 0 {
+			fallback = j
+		}
+	}
+	return fallback
+}
+
+// parent returns the index of the execution that started the one at i as a
+// child, or -1 if none.
+func (h Histories) parent(i int) int {
+	attrs := startedAttributes(h[i])
+	parentExec := attrs.GetParentWorkflowExecution()
+	if parentExec.GetWorkflowId() == "" || attrs.GetWorkflowId() == "" {
+		return -1
+	}
+	fallback := -1
+	for j, other := range h {
+		otherAttrs := startedAttributes(other)
+		if j == i || otherAttrs.GetWorkflowId() != parentExec.GetWorkflowId() ||
+			!initiatedChild(other, attrs.GetParentInitiatedEventId(), attrs.GetWorkflowId()) {
+			continue
+		} else if otherAttrs.GetOriginalExecutionRunId() == parentExec.GetRunId() {
+			return j
+		} else if fallback < 0 {
+			fallback = j
+		}
+	}
+	return fallback
+}
+
+// EqualExecutions returns whether both sets of histories have the same
+// execution names and the histories with the same name are equal. The names
+// are from Histories.Executions and are in the same order as the histories.
+func EqualExecutions(a Histories, aNames []string, b Histories, bNames []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	bByName := make(map[string]*history.History, len(b))
+	for i, hist := range b {
+		bByName[bNames[i]] = hist
+	}
+	for i, hist := range a {
+		if other, ok := bByName[aNames[i]]; !ok || !proto.Equal(hist, other) {
+			return false
+		}
+	}
+	return true
+}
+
+func startedAttributes(h *history.History) *history.WorkflowExecutionStartedEventAttributes {
+	if len(h.GetEvents()) == 0 {
+		return nil
+	}
+	return h.Events[0].GetWorkflowExecutionStartedEventAttributes()
+}
+
+// initiatedChild returns whether the event with the ID in the history started a
+// child with the workflow ID.
+func initiatedChild(h *history.History, eventID int64, workflowID string) bool {
+	for _, event := range h.GetEvents() {
+		if event.EventId == eventID {
+			return event.GetStartChildWorkflowExecutionInitiatedEventAttributes().GetWorkflowId() == workflowID
+		}
+	}
+	return false
+}
+
+// newExecutionRunID returns the run ID the history's execution was continued
+// as, or empty if none.
+func newExecutionRunID(h *history.History) string {
+	if len(h.GetEvents()) == 0 {
+		return ""
+	}
+	switch attrs := h.Events[len(h.Events)-1].Attributes.(type) {
+	case *history.HistoryEvent_WorkflowExecutionContinuedAsNewEventAttributes:
+		return attrs.WorkflowExecutionContinuedAsNewEventAttributes.GetNewExecutionRunId()
+	case *history.HistoryEvent_WorkflowExecutionCompletedEventAttributes:
+		return attrs.WorkflowExecutionCompletedEventAttributes.GetNewExecutionRunId()
+	case *history.HistoryEvent_WorkflowExecutionFailedEventAttributes:
+		return attrs.WorkflowExecutionFailedEventAttributes.GetNewExecutionRunId()
+	case *history.HistoryEvent_WorkflowExecutionTimedOutEventAttributes:
+		return attrs.WorkflowExecutionTimedOutEventAttributes.GetNewExecutionRunId()
+	}
+	return ""
+}
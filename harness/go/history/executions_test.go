@@ -0,0 +1,57 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutions(t *testing.T) {
+	assert.Equal(t, []string{"Parent", "Parent+continued", "Parent/Child@6+retry", "Parent/Child@6", "Parent#2"},
+		testExecutionSet("").Executions())
+
+	// Once scrubbed, the relationships are gone
+	scrubbed := testExecutionSet("")
+	scrubbed.ScrubRunSpecificFields()
+	assert.Equal(t, []string{"Parent", "Parent#2", "Child", "Child#2", "Parent#3"}, scrubbed.Executions())
+}
+
+func TestDiffExecutionsPairsStructurally(t *testing.T) {
+	expected, actual := testExecutionSet("expected-"), testExecutionSet("actual-")
+	// Same executions in another order, with the first child attempt differing
+	actual[2], actual[3] = actual[3], actual[2]
+	timer := testTimerStarted("2")
+	timer.EventId = 2
+	actual[2].Events = append(actual[2].Events, timer)
+	expectedNames, actualNames := expected.Executions(), actual.Executions()
+	for _, hists := range []Histories{expected, actual} {
+		hists.ScrubRunSpecificFields()
+	}
+	assert.False(t, EqualExecutions(expected, expectedNames, actual, actualNames))
+	assert.Equal(t, "workflow Child (execution Parent/Child@6) event 2: unexpected TimerStarted(timerId=2)",
+		DiffExecutions(expected, expectedNames, actual, actualNames).String())
+
+	actual[2].Events = actual[2].Events[:1]
+	assert.True(t, EqualExecutions(expected, expectedNames, actual, actualNames))
+	// Positional comparison would have paired the child attempts the wrong way
+	assert.False(t, expected.Equals(actual))
+}
+
+func TestPairExecutionsWithScrubbedStoredSet(t *testing.T) {
+	stored, fetched := testExecutionSet("stored-"), testExecutionSet("fetched-")
+	stored.ScrubRunSpecificFields()
+	storedNames, fetchedNames := PairExecutions(stored, fetched)
+	assert.Equal(t, []string{"Parent", "Parent#2", "Child", "Child#2", "Parent#3"}, storedNames)
+	assert.Equal(t, storedNames, fetchedNames)
+	fetched.ScrubRunSpecificFields()
+	assert.True(t, EqualExecutions(stored, storedNames, fetched, fetchedNames))
+	assert.Empty(t, DiffExecutions(stored, storedNames, fetched, fetchedNames))
+
+	// Without the fallback, no execution would pair with a structural name
+	assert.False(t, EqualExecutions(stored, stored.Executions(), fetched, testExecutionSet("fetched-").Executions()))
+
+	// Sets that both have relationships are paired structurally
+	storedNames, fetchedNames = PairExecutions(testExecutionSet("stored-"), testExecutionSet("fetched-"))
+	assert.Equal(t, testExecutionSet("").Executions(), storedNames)
+	assert.Equal(t, storedNames, fetchedNames)
+}
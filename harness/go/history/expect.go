@@ -0,0 +1,373 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/history/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// snippetLen is the number of events shown around the position an expectation
+// failed at.
+const snippetLen = 5
+
+// EventMatcher matches history events for Expectation assertions. Matchers are
+// created with Event or one of the helpers like ActivityScheduled, and refined
+// with Where and WithAttr. AnyN is a special matcher that only bounds the
+// number of events between two other matchers in InOrder.
+type EventMatcher struct {
+	desc  string
+	match func(*history.HistoryEvent) bool
+	// Only set for AnyN
+	gap      bool
+	min, max int
+}
+
+// Event matches events of the given type.
+func Event(eventType enums.EventType) EventMatcher {
+	return EventMatcher{
+		desc:  eventType.String(),
+		match: func(event *history.HistoryEvent) bool { return event.EventType == eventType },
+	}
+}
+
+// AnyEvent matches every event.
+func AnyEvent() EventMatcher {
+	return EventMatcher{desc: "any event", match: func(*history.HistoryEvent) bool { return true }}
+}
+
+// AnyN, in InOrder, requires between min and max events, inclusive, between
+// the matchers before and after it instead of allowing any number. Use a
+// negative max for no upper bound. It cannot be used as the last matcher or
+// outside InOrder.
+func AnyN(min, max int) EventMatcher {
+	if max < 0 {
+		max = math.MaxInt
+	}
+	desc := fmt.Sprintf("%v to %v events", min, max)
+	if max == math.MaxInt {
+		desc = fmt.Sprintf("at least %v events", min)
+	}
+	return EventMatcher{desc: desc, match: func(*history.HistoryEvent) bool { return true }, gap: true, min: min, max: max}
+}
+
+// Not matches every event the given matcher does not.
+func Not(m EventMatcher) EventMatcher {
+	return EventMatcher{
+		desc:  "not " + m.desc,
+		match: func(event *history.HistoryEvent) bool { return !m.match(event) },
+	}
+}
+
+// Where returns a matcher that also requires the predicate to be true. The
+// description is shown in failures.
+func (m EventMatcher) Where(desc string, pred func(*history.HistoryEvent) bool) EventMatcher {
+	match := m.match
+	return EventMatcher{
+		desc:  m.desc + " where " + desc,
+		match: func(event *history.HistoryEvent) bool { return match(event) && pred(event) },
+	}
+}
+
+// WithAttr returns a matcher that also requires the attribute at the path to
+// have the given value. The path is dot-separated field names relative to the
+// event's attributes, like the paths of scrub rules but without wildcards, e.g.
+// "activityType.name". The value is compared to the field's string form, which
+// is the proto name for enums, e.g. "RETRY_STATE_TIMEOUT".
+func (m EventMatcher) WithAttr(path string, value string) EventMatcher {
+	return m.Where(path+"="+value, func(event *history.HistoryEvent) bool {
+		actual, ok := attributeValue(event, path)
+		return ok && actual == value
+	})
+}
+
+// String returns the matcher description.
+func (m EventMatcher) String() string {
+	return m.desc
+}
+
+// ActivityScheduled matches ActivityTaskScheduled events of the activity type,
+// or of any activity type if empty.
+func ActivityScheduled(activityType string) EventMatcher {
+	m := Event(enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED)
+	if activityType != "" {
+		m = m.WithAttr("activityType.name", activityType)
+	}
+	return m
+}
+
+// ActivityCompleted matches ActivityTaskCompleted events.
+func ActivityCompleted() EventMatcher {
+	return Event(enums.EVENT_TYPE_ACTIVITY_TASK_COMPLETED)
+}
+
+// ActivityFailed matches ActivityTaskFailed events.
+func ActivityFailed() EventMatcher {
+	return Event(enums.EVENT_TYPE_ACTIVITY_TASK_FAILED)
+}
+
+// TimerStarted matches TimerStarted events.
+func TimerStarted() EventMatcher {
+	return Event(enums.EVENT_TYPE_TIMER_STARTED)
+}
+
+// TimerFired matches TimerFired events.
+func TimerFired() EventMatcher {
+	return Event(enums.EVENT_TYPE_TIMER_FIRED)
+}
+
+// SignalReceived matches WorkflowExecutionSignaled events of the signal name,
+// or of any signal if empty.
+func SignalReceived(signalName string) EventMatcher {
+	m := Event(enums.EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED)
+	if signalName != "" {
+		m = m.WithAttr("signalName", signalName)
+	}
+	return m
+}
+
+// UpdateAccepted matches WorkflowExecutionUpdateAccepted events of the update
+// name, or of any update if empty.
+func UpdateAccepted(updateName string) EventMatcher {
+	m := Event(enums.EVENT_TYPE_WORKFLOW_EXECUTION_UPDATE_ACCEPTED)
+	if updateName != "" {
+		m = m.WithAttr("acceptedRequest.input.name", updateName)
+	}
+	return m
+}
+
+// UpdateCompleted matches WorkflowExecutionUpdateCompleted events.
+func UpdateCompleted() EventMatcher {
+	return Event(enums.EVENT_TYPE_WORKFLOW_EXECUTION_UPDATE_COMPLETED)
+}
+
+// UpdateRejected matches WorkflowExecutionUpdateRejected events.
+func UpdateRejected() EventMatcher {
+	return Event(enums.EVENT_TYPE_WORKFLOW_EXECUTION_UPDATE_REJECTED)
+}
+
+// ChildWorkflowInitiated matches StartChildWorkflowExecutionInitiated events of
+// the workflow type, or of any workflow type if empty.
+func ChildWorkflowInitiated(workflowType string) EventMatcher {
+	m := Event(enums.EVENT_TYPE_START_CHILD_WORKFLOW_EXECUTION_INITIATED)
+	if workflowType != "" {
+		m = m.WithAttr("workflowType.name", workflowType)
+	}
+	return m
+}
+
+// WorkflowCompleted matches WorkflowExecutionCompleted events.
+func WorkflowCompleted() EventMatcher {
+	return Event(enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED)
+}
+
+// WorkflowFailed matches WorkflowExecutionFailed events.
+func WorkflowFailed() EventMatcher {
+	return Event(enums.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED)
+}
+
+// WorkflowContinuedAsNew matches WorkflowExecutionContinuedAsNew events.
+func WorkflowContinuedAsNew() EventMatcher {
+	return Event(enums.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW)
+}
+
+// Expectation makes assertions on the events of a single history. Every
+// assertion returns an error describing the failure so it can be returned from
+// a feature's CheckHistory.
+type Expectation struct {
+	history *history.History
+}
+
+// Expect creates an expectation for the history.
+func Expect(h *history.History) *Expectation {
+	return &Expectation{history: h}
+}
+
+// ExpectWorkflow creates an expectation for the first history of the workflow
+// type, or returns an error if there is none.
+func ExpectWorkflow(h Histories, workflowType string) (*Expectation, error) {
+	for _, hist := range h {
+		if name, _ := historyFirstEventName(hist); name == workflowType {
+			return Expect(hist), nil
+		}
+	}
+	return nil, fmt.Errorf("no history of workflow %v", workflowType)
+}
+
+// InOrder checks that events matching each matcher appear in the given order.
+// By default any number of other events may come between two matchers. AnyN
+// between two matchers instead requires the number of events between them to
+// be in its range. When no ordering satisfies every matcher, the error names
+// the first matcher that could not be matched after the furthest partial match,
+// with the index of the event searched from and a snippet of the events there.
+func (e *Expectation) InOrder(matchers ...EventMatcher) error {
+	if len(matchers) == 0 {
+		return nil
+	} else if matchers[len(matchers)-1].gap {
+		return fmt.Errorf("AnyN cannot be the last matcher")
+	}
+	// Each step is a matcher with the gap allowed before it
+	type step struct {
+		matcher  EventMatcher
+		min, max int
+	}
+	var steps []step
+	gapMin, gapMax := 0, math.MaxInt
+	for _, m := range matchers {
+		if m.gap {
+			gapMin, gapMax = m.min, m.max
+			continue
+		}
+		steps = append(steps, step{matcher: m, min: gapMin, max: gapMax})
+		gapMin, gapMax = 0, math.MaxInt
+	}
+
+	// Search with backtracking, remembering positions that failed and the
+	// furthest step reached for reporting
+	events := e.history.GetEvents()
+	failed := map[[2]int]bool{}
+	furthestStep, furthestEvent := 0, 0
+	var search func(stepIndex, from int) bool
+	search = func(stepIndex, from int) bool {
+		if stepIndex == len(steps) {
+			return true
+		} else if failed[[2]int{stepIndex, from}] {
+			return false
+		}
+		if stepIndex > furthestStep || (stepIndex == furthestStep && from > furthestEvent) {
+			furthestStep, furthestEvent = stepIndex, from
+		}
+		s := steps[stepIndex]
+		for i := from + s.min; i < len(events) && i-from <= s.max; i++ {
+			if s.matcher.match(events[i]) && search(stepIndex+1, i+1) {
+				return true
+			}
+		}
+		failed[[2]int{stepIndex, from}] = true
+		return false
+	}
+	if search(0, 0) {
+		return nil
+	}
+	s := steps[furthestStep]
+	msg := fmt.Sprintf("expected %v", s.matcher)
+	if s.min > 0 || s.max != math.MaxInt {
+		msg += fmt.Sprintf(" after %v", AnyN(s.min, s.max))
+	}
+	if furthestStep > 0 {
+		msg += fmt.Sprintf(" following %v", steps[furthestStep-1].matcher)
+	}
+	return fmt.Errorf("%v%v at event index %v, found:\n%v",
+		e.workflowPrefix(), msg, furthestEvent, e.snippet(furthestEvent))
+}
+
+// None checks that no event matches any of the matchers.
+func (e *Expectation) None(matchers ...EventMatcher) error {
+	for _, m := range matchers {
+		if m.gap {
+			return fmt.Errorf("AnyN can only be used in InOrder")
+		}
+		for i, event := range e.history.GetEvents() {
+			if m.match(event) {
+				return fmt.Errorf("%vexpected no %v, found one at event index %v:\n%v",
+					e.workflowPrefix(), m, i, e.snippet(i))
+			}
+		}
+	}
+	return nil
+}
+
+// Count checks that exactly n events match the matcher.
+func (e *Expectation) Count(m EventMatcher, n int) error {
+	if m.gap {
+		return fmt.Errorf("AnyN can only be used in InOrder")
+	}
+	var indexes []int
+	for i, event := range e.history.GetEvents() {
+		if m.match(event) {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == n {
+		return nil
+	} else if len(indexes) > n {
+		// Show where the first unexpected one is
+		i := indexes[n]
+		return fmt.Errorf("%vexpected %v %v event(s), found %v, extra one at event index %v:\n%v",
+			e.workflowPrefix(), n, m, len(indexes), i, e.snippet(i))
+	}
+	return fmt.Errorf("%vexpected %v %v event(s), found %v at event indexes %v",
+		e.workflowPrefix(), n, m, len(indexes), indexes)
+}
+
+// workflowPrefix returns the workflow type for the start of failure messages.
+func (e *Expectation) workflowPrefix() string {
+	if name, err := historyFirstEventName(e.history); err == nil {
+		return "workflow " + name + ": "
+	}
+	return ""
+}
+
+// snippet returns the events around the index, one per line with their index,
+// marking the one at the index.
+func (e *Expectation) snippet(index int) string {
+	events := e.history.GetEvents()
+	if len(events) == 0 {
+		return "  <no events>"
+	}
+	start := max(0, min(index, len(events))-snippetLen/2)
+	end := min(len(events), start+snippetLen)
+	var b strings.Builder
+	for i := start; i < end; i++ {
+		marker := " "
+		if i == index {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%v %v: %v\n", marker, i, describeEvent(events[i]))
+	}
+	if index >= len(events) {
+		fmt.Fprintf(&b, "> %v: <end of history>\n", len(events))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// attributeValue returns the string form of the value at the dot-separated path
+// relative to the event's attributes, or false if any part of the path is not
+// set or not a field.
+func attributeValue(event *history.HistoryEvent, path string) (string, bool) {
+	attrsField := attributesField(event.EventType)
+	if attrsField == nil || !event.ProtoReflect().Has(attrsField) {
+		return "", false
+	}
+	msg := event.ProtoReflect().Get(attrsField).Message()
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		field := findField(msg.Descriptor(), segment)
+		if field == nil || field.IsList() || field.IsMap() || !msg.Has(field) {
+			return "", false
+		}
+		value := msg.Get(field)
+		if i < len(segments)-1 {
+			if field.Message() == nil {
+				return "", false
+			}
+			msg = value.Message()
+			continue
+		}
+		switch field.Kind() {
+		case protoreflect.MessageKind, protoreflect.GroupKind:
+			return "", false
+		case protoreflect.EnumKind:
+			if enumValue := field.Enum().Values().ByNumber(value.Enum()); enumValue != nil {
+				return string(enumValue.Name()), true
+			}
+		case protoreflect.BytesKind:
+			return string(value.Bytes()), true
+		}
+		return fmt.Sprint(value.Interface()), true
+	}
+	return "", false
+}
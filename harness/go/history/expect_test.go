@@ -0,0 +1,51 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.temporal.io/api/enums/v1"
+)
+
+func TestExpectInOrder(t *testing.T) {
+	e := Expect(testWorkflowHistory())
+	require.NoError(t, e.InOrder(ActivityScheduled("Foo"), TimerStarted(), AnyN(0, 3), WorkflowCompleted()))
+	require.NoError(t, e.InOrder(ActivityScheduled(""), AnyN(0, 0), ActivityCompleted()))
+	require.NoError(t, e.InOrder(TimerStarted().WithAttr("timerId", "1"), WorkflowCompleted()))
+
+	// Too few events allowed between
+	err := e.InOrder(ActivityScheduled("Foo"), TimerStarted(), AnyN(0, 1), WorkflowCompleted())
+	require.ErrorContains(t, err, "workflow Workflow: expected WorkflowExecutionCompleted after 0 to 1 events following TimerStarted at event index 5")
+	require.ErrorContains(t, err, "> 5: WorkflowTaskScheduled")
+
+	// Wrong attribute
+	err = e.InOrder(ActivityScheduled("Bar"))
+	require.ErrorContains(t, err, "expected ActivityTaskScheduled where activityType.name=Bar at event index 0")
+	require.ErrorContains(t, err, "  2: ActivityTaskScheduled(activityType=Foo)")
+
+	// Out of order
+	err = e.InOrder(TimerStarted(), ActivityScheduled("Foo"))
+	require.ErrorContains(t, err, "expected ActivityTaskScheduled where activityType.name=Foo following TimerStarted at event index 5")
+
+	require.Error(t, e.InOrder(TimerStarted(), AnyN(0, 1)))
+}
+
+func TestExpectNoneAndCount(t *testing.T) {
+	e := Expect(testWorkflowHistory())
+	require.NoError(t, e.None(UpdateRejected(), ActivityScheduled("Bar")))
+	err := e.None(TimerStarted())
+	require.ErrorContains(t, err, "expected no TimerStarted, found one at event index 4")
+	require.ErrorContains(t, err, "> 4: TimerStarted(timerId=1)")
+
+	require.NoError(t, e.Count(Event(enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED), 2))
+	require.NoError(t, e.Count(Not(Event(enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED)), 6))
+	require.ErrorContains(t, e.Count(TimerStarted(), 0), "extra one at event index 4")
+	require.ErrorContains(t, e.Count(TimerFired(), 1), "found 0 at event indexes []")
+
+	exp, err := ExpectWorkflow(Histories{testWorkflowHistory()}, "Workflow")
+	require.NoError(t, err)
+	require.NoError(t, exp.InOrder(WorkflowCompleted()))
+	_, err = ExpectWorkflow(Histories{testWorkflowHistory()}, "Other")
+	require.Error(t, err)
+}
@@ -13,33 +13,59 @@ import (
 	"go.temporal.io/sdk/client"
 )
 
+const (
+	// IgnoredWorkflowIDPrefix marks workflows whose ID starts with it as
+	// intentionally left open or otherwise not part of the feature's history.
+	IgnoredWorkflowIDPrefix = "features-ignore-history-"
+	// IgnoredMemoKey marks workflows with this memo key set, to any value, the
+	// same as IgnoredWorkflowIDPrefix.
+	IgnoredMemoKey = "featuresIgnoreHistory"
+)
+
+// IsIgnored returns whether the execution is marked to be left out of history
+// fetching via IgnoredWorkflowIDPrefix or IgnoredMemoKey.
+func IsIgnored(exec *workflow.WorkflowExecutionInfo) bool {
+	if strings.HasPrefix(exec.GetExecution().GetWorkflowId(), IgnoredWorkflowIDPrefix) {
+		return true
+	}
+	_, ok := exec.GetMemo().GetFields()[IgnoredMemoKey]
+	return ok
+}
+
 // Fetcher fetches histories.
 type Fetcher struct {
 	Client    client.Client
 	Namespace string
 	TaskQueue string
+	// Suffixes appended to TaskQueue to get other task queues, e.g. of a
+	// feature's named workers, whose executions are fetched too.
+	TaskQueueSuffixes []string
 	// Approximate value, is given leeway
 	FeatureStarted time.Time
 }
 
 // Fetch returns histories sorted via Histories.Sort. This will continually try
-// to find at least one completed workflow or fail.
+// to find at least one completed workflow or fail. Executions marked as ignored
+// (see IsIgnored) are left out and may stay open.
 func (f *Fetcher) Fetch(ctx context.Context) (Histories, error) {
 	// Collect executions. Try until there are no open executions and at least one
 	// closed execution. The reason we do this is the server can still show a
 	// workflow as not complete or not present even though the SDK has been told
 	// it is complete.
-	// TODO(cretz): Provide way to ignore specific still-open workflows. Maybe
-	// they can have a header or a name-prefix or something else.
 	var execs []*workflow.WorkflowExecutionInfo
 	var stillRunning []string
 	const maxOpenWait = 5 * time.Second
 	for start := time.Now(); time.Since(start) < maxOpenWait; {
-		var err error
-		execs, err = f.GetExecutions(ctx)
+		allExecs, err := f.GetExecutions(ctx)
 		if err != nil {
 			return nil, err
 		}
+		execs = execs[:0]
+		for _, exec := range allExecs {
+			if !IsIgnored(exec) {
+				execs = append(execs, exec)
+			}
+		}
 		stillRunning = stillRunning[:0]
 		for _, exec := range execs {
 			if exec.Status == enums.WORKFLOW_EXECUTION_STATUS_RUNNING {
@@ -85,6 +111,16 @@ func (f *Fetcher) Fetch(ctx context.Context) (Histories, error) {
 	return ret, nil
 }
 
+// TaskQueues returns the task queue followed by the task queue with each
+// suffix appended.
+func (f *Fetcher) TaskQueues() []string {
+	taskQueues := []string{f.TaskQueue}
+	for _, suffix := range f.TaskQueueSuffixes {
+		taskQueues = append(taskQueues, f.TaskQueue+suffix)
+	}
+	return taskQueues
+}
+
 // GetExecutions returns all open/closed executions.
 func (f *Fetcher) GetExecutions(ctx context.Context) ([]*workflow.WorkflowExecutionInfo, error) {
 	// Get all workflows started shortly before the runner started
@@ -93,7 +129,12 @@ func (f *Fetcher) GetExecutions(ctx context.Context) ([]*workflow.WorkflowExecut
 	seenExecs := map[string]bool{}
 	var nextPageToken []byte
 
-	query := fmt.Sprintf("StartTime >= '%s' and TaskQueue = '%s'", earliest.Format(time.RFC3339), f.TaskQueue)
+	taskQueueQueries := make([]string, 0, 1+len(f.TaskQueueSuffixes))
+	for _, taskQueue := range f.TaskQueues() {
+		taskQueueQueries = append(taskQueueQueries, fmt.Sprintf("TaskQueue = '%s'", taskQueue))
+	}
+	query := fmt.Sprintf("StartTime >= '%s' and (%s)", earliest.Format(time.RFC3339),
+		strings.Join(taskQueueQueries, " or "))
 
 	for {
 		resp, err := f.Client.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
@@ -0,0 +1,29 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/workflow/v1"
+)
+
+func TestIsIgnored(t *testing.T) {
+	exec := func(id string, memo map[string]*common.Payload) *workflow.WorkflowExecutionInfo {
+		return &workflow.WorkflowExecutionInfo{
+			Execution: &common.WorkflowExecution{WorkflowId: id},
+			Memo:      &common.Memo{Fields: memo},
+		}
+	}
+	assert.False(t, IsIgnored(exec("my-workflow", nil)))
+	assert.True(t, IsIgnored(exec(IgnoredWorkflowIDPrefix+"helper", nil)))
+	assert.True(t, IsIgnored(exec("my-workflow", map[string]*common.Payload{IgnoredMemoKey: {}})))
+	assert.False(t, IsIgnored(&workflow.WorkflowExecutionInfo{}))
+}
+
+func TestFetcherTaskQueues(t *testing.T) {
+	assert.Equal(t, []string{"tq"}, (&Fetcher{TaskQueue: "tq"}).TaskQueues())
+	assert.Equal(t, []string{"tq", "tq-v1", "tq-v2"},
+		(&Fetcher{TaskQueue: "tq", TaskQueueSuffixes: []string{"-v1", "-v2"}}).TaskQueues())
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 
 	"go.temporal.io/api/common/v1"
@@ -68,20 +69,7 @@ func (h *Histories) UnmarshalJSON(b []byte) error {
 
 // MarshalJSON converts the histories to JSON.
 func (h Histories) MarshalJSON() ([]byte, error) {
-	// Copy and sort each history by its first event's name
-	sorted := make(Histories, len(h))
-	copy(sorted, h)
-	var err error
-	sort.Slice(sorted, func(i, j int) bool {
-		a, aErr := historyFirstEventName(sorted[i])
-		b, bErr := historyFirstEventName(sorted[j])
-		if err == nil && aErr != nil {
-			err = aErr
-		} else if err == nil && bErr != nil {
-			err = bErr
-		}
-		return a < b
-	})
+	sorted := h.sortedCopy()
 
 	// Marshal each history, then marshal the whole thing
 	halfMarshaled := make([]json.RawMessage, len(sorted))
@@ -95,6 +83,62 @@ func (h Histories) MarshalJSON() ([]byte, error) {
 	return json.Marshal(halfMarshaled)
 }
 
+// MarshalBinary converts the histories to binary protobuf. This is the same
+// encoding as a message with a single "repeated temporal.api.history.v1.History
+// histories = 1" field.
+func (h Histories) MarshalBinary() ([]byte, error) {
+	sorted := h.sortedCopy()
+	var b []byte
+	for _, history := range sorted {
+		histBytes, err := proto.Marshal(history)
+		if err != nil {
+			return nil, fmt.Errorf("failed marshaling history: %w", err)
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, histBytes)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary converts binary protobuf from MarshalBinary to histories.
+func (h *Histories) UnmarshalBinary(b []byte) error {
+	var hists Histories
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		} else if num != 1 || typ != protowire.BytesType {
+			return fmt.Errorf("unexpected field %v of type %v", num, typ)
+		}
+		b = b[n:]
+		histBytes, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var hist history.History
+		if err := (proto.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(histBytes, &hist); err != nil {
+			return err
+		}
+		hists = append(hists, &hist)
+	}
+	*h = hists
+	return nil
+}
+
+// sortedCopy returns a copy of the histories sorted by each history's first
+// event's name.
+func (h Histories) sortedCopy() Histories {
+	sorted := make(Histories, len(h))
+	copy(sorted, h)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, _ := historyFirstEventName(sorted[i])
+		b, _ := historyFirstEventName(sorted[j])
+		return a < b
+	})
+	return sorted
+}
+
 // Clone performs a deep clone of histories.
 func (h Histories) Clone() Histories {
 	ret := make(Histories, len(h))
@@ -175,6 +219,8 @@ func scrubRunSpecificScalars(v interface{}) {
 		v.OriginalExecutionRunId = ""
 		v.Identity = ""
 		v.FirstExecutionRunId = ""
+		// Whether the execution was continued is kept in its Executions name
+		v.ContinuedExecutionRunId = ""
 		v.WorkflowExecutionExpirationTime = nil
 		// TODO: Shouldn't be fully ignorable, but should be ignorable if not present in old hist
 		v.WorkflowId = ""
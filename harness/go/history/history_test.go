@@ -8,6 +8,7 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
 	"go.temporal.io/api/history/v1"
 	"go.temporal.io/api/taskqueue/v1"
 )
@@ -53,3 +54,126 @@ func TestScrub(t *testing.T) {
 	}
 	assert.Equal(t, expected, actual)
 }
+
+// The fixtures below are shared by the tests of this package. Event IDs are
+// assigned by position when a history is built.
+
+func testHistory(workflowType string, events ...*history.HistoryEvent) *history.History {
+	return testStartedHistory(workflowType, &history.WorkflowExecutionStartedEventAttributes{}, events...)
+}
+
+func testStartedHistory(
+	workflowType string,
+	attrs *history.WorkflowExecutionStartedEventAttributes,
+	events ...*history.HistoryEvent,
+) *history.History {
+	attrs.WorkflowType = &common.WorkflowType{Name: workflowType}
+	started := &history.HistoryEvent{
+		EventType: enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+		Attributes: &history.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+			WorkflowExecutionStartedEventAttributes: attrs,
+		},
+	}
+	hist := &history.History{Events: append([]*history.HistoryEvent{started}, events...)}
+	for i, event := range hist.Events {
+		event.EventId = int64(i + 1)
+	}
+	return hist
+}
+
+func testEvent(eventType enums.EventType) *history.HistoryEvent {
+	return &history.HistoryEvent{EventType: eventType}
+}
+
+func testActivityScheduled(activityType, input string) *history.HistoryEvent {
+	return &history.HistoryEvent{
+		EventType: enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+		Attributes: &history.HistoryEvent_ActivityTaskScheduledEventAttributes{
+			ActivityTaskScheduledEventAttributes: &history.ActivityTaskScheduledEventAttributes{
+				ActivityType: &common.ActivityType{Name: activityType},
+				Input:        &common.Payloads{Payloads: []*common.Payload{{Data: []byte(input)}}},
+			},
+		},
+	}
+}
+
+func testTimerStarted(id string) *history.HistoryEvent {
+	return &history.HistoryEvent{
+		EventType: enums.EVENT_TYPE_TIMER_STARTED,
+		Attributes: &history.HistoryEvent_TimerStartedEventAttributes{
+			TimerStartedEventAttributes: &history.TimerStartedEventAttributes{TimerId: id},
+		},
+	}
+}
+
+func testStartChild(workflowID string) *history.HistoryEvent {
+	return &history.HistoryEvent{
+		EventType: enums.EVENT_TYPE_START_CHILD_WORKFLOW_EXECUTION_INITIATED,
+		Attributes: &history.HistoryEvent_StartChildWorkflowExecutionInitiatedEventAttributes{
+			StartChildWorkflowExecutionInitiatedEventAttributes: &history.StartChildWorkflowExecutionInitiatedEventAttributes{
+				WorkflowId:   workflowID,
+				WorkflowType: &common.WorkflowType{Name: "Child"},
+			},
+		},
+	}
+}
+
+// testWorkflowHistory returns a single workflow that runs activity Foo, then
+// starts timer 1 and completes.
+func testWorkflowHistory() *history.History {
+	return testHistory("Workflow",
+		testEvent(enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED),
+		testActivityScheduled("Foo", "in"),
+		testEvent(enums.EVENT_TYPE_ACTIVITY_TASK_COMPLETED),
+		testTimerStarted("1"),
+		testEvent(enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED),
+		testEvent(enums.EVENT_TYPE_WORKFLOW_TASK_STARTED),
+		testEvent(enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED),
+	)
+}
+
+// testExecutionSet returns a parent that starts a timer and a child in its
+// first workflow task then continues as new, its successor, a retry of that
+// child and the child's first attempt, plus a second unrelated parent. The run
+// IDs are prefixed so different sets have different IDs.
+func testExecutionSet(runPrefix string) Histories {
+	timer := testTimerStarted("1")
+	timer.GetTimerStartedEventAttributes().WorkflowTaskCompletedEventId = 4
+	return Histories{
+		testStartedHistory("Parent", &history.WorkflowExecutionStartedEventAttributes{
+			WorkflowId:             "parent",
+			OriginalExecutionRunId: runPrefix + "parent-1",
+		},
+			testEvent(enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED),
+			testEvent(enums.EVENT_TYPE_WORKFLOW_TASK_STARTED),
+			testEvent(enums.EVENT_TYPE_WORKFLOW_TASK_COMPLETED),
+			timer,
+			testStartChild("child"),
+			testEvent(enums.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW),
+		),
+		testStartedHistory("Parent", &history.WorkflowExecutionStartedEventAttributes{
+			WorkflowId:              "parent",
+			OriginalExecutionRunId:  runPrefix + "parent-2",
+			ContinuedExecutionRunId: runPrefix + "parent-1",
+			Initiator:               enums.CONTINUE_AS_NEW_INITIATOR_WORKFLOW,
+		}),
+		testStartedHistory("Child", &history.WorkflowExecutionStartedEventAttributes{
+			WorkflowId:              "child",
+			OriginalExecutionRunId:  runPrefix + "child-2",
+			ContinuedExecutionRunId: runPrefix + "child-1",
+			Initiator:               enums.CONTINUE_AS_NEW_INITIATOR_RETRY,
+			ParentWorkflowExecution: &common.WorkflowExecution{WorkflowId: "parent", RunId: runPrefix + "parent-1"},
+			ParentInitiatedEventId:  6,
+		}),
+		testStartedHistory("Child", &history.WorkflowExecutionStartedEventAttributes{
+			WorkflowId:              "child",
+			OriginalExecutionRunId:  runPrefix + "child-1",
+			ParentWorkflowExecution: &common.WorkflowExecution{WorkflowId: "parent", RunId: runPrefix + "parent-1"},
+			ParentInitiatedEventId:  6,
+		}),
+		testStartedHistory("Parent", &history.WorkflowExecutionStartedEventAttributes{
+			WorkflowId:             "other-parent",
+			OriginalExecutionRunId: runPrefix + "other-parent-1",
+		}),
+	}
+}
@@ -0,0 +1,274 @@
+package history
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"go.temporal.io/api/history/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// RenderText renders histories as plain text, one line per timeline entry.
+	RenderText = "text"
+	// RenderMermaid renders histories as a Mermaid flowchart with a subgraph per
+	// execution.
+	RenderMermaid = "mermaid"
+	// RenderHTML renders histories as a single self-contained HTML page.
+	RenderHTML = "html"
+)
+
+// RenderFormats are all formats Histories.Render supports.
+var RenderFormats = []string{RenderText, RenderMermaid, RenderHTML}
+
+//go:embed render.html.tmpl
+var renderHTMLTemplate string
+
+// timeline is the rendered form of a single execution.
+type timeline struct {
+	// Index of the execution, used for anchors and node IDs
+	Index   int
+	Name    string
+	Entries []*timelineEntry
+}
+
+// timelineEntry is one or more events shown together. Workflow task events are
+// collapsed into a single entry per workflow task.
+type timelineEntry struct {
+	FirstEventID int64
+	LastEventID  int64
+	// Category of the entry, e.g. "activity" or "workflow task"
+	Kind  string
+	Label string
+	// Whether the event was the result of a workflow command
+	Command bool
+	// Execution this entry relates to, or nil if none
+	Link *timeline
+	// How the linked execution relates, e.g. "child" or "continued as"
+	LinkLabel string
+}
+
+// Events returns the event ID, or range of event IDs, of the entry.
+func (t *timelineEntry) Events() string {
+	if t.FirstEventID == t.LastEventID {
+		return fmt.Sprint(t.FirstEventID)
+	}
+	return fmt.Sprintf("%v-%v", t.FirstEventID, t.LastEventID)
+}
+
+// Render writes the histories in the given format, one of RenderFormats. Each
+// execution is shown as a timeline of workflow tasks, commands, activities,
+// timers, signals, updates, children, and other events, with links to the
+// executions it started, continued as, or was started by. Relationships are
+// taken from history attributes, so this should be called before scrubbing.
+func (h Histories) Render(w io.Writer, format string) error {
+	timelines := h.timelines()
+	switch format {
+	case RenderText:
+		return renderText(w, timelines)
+	case RenderMermaid:
+		return renderMermaid(w, timelines)
+	case RenderHTML:
+		tmpl, err := template.New("history").Parse(renderHTMLTemplate)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, timelines)
+	}
+	return fmt.Errorf("unknown render format %q, must be one of: %v", format, strings.Join(RenderFormats, ", "))
+}
+
+// timelines builds the timeline of every execution, in order, with links
+// resolved.
+func (h Histories) timelines() []*timeline {
+	names := h.Executions()
+	timelines := make([]*timeline, len(h))
+	for i := range h {
+		timelines[i] = &timeline{Index: i, Name: names[i]}
+	}
+	// Children by parent index and initiating event, and successors by
+	// predecessor index
+	type childKey struct {
+		parent  int
+		eventID int64
+	}
+	children := map[childKey]int{}
+	successors := map[int]int{}
+	for i := range h {
+		if pred := h.predecessor(i); pred >= 0 {
+			successors[pred] = i
+		} else if parent := h.parent(i); parent >= 0 {
+			children[childKey{parent, startedAttributes(h[i]).GetParentInitiatedEventId()}] = i
+		}
+	}
+
+	for i, hist := range h {
+		var workflowTask *timelineEntry
+		for _, event := range hist.GetEvents() {
+			kind := eventKind(event)
+			// Collapse the events of a workflow task
+			if kind == "workflow task" {
+				if workflowTask == nil {
+					workflowTask = &timelineEntry{FirstEventID: event.EventId, Kind: kind}
+					timelines[i].Entries = append(timelines[i].Entries, workflowTask)
+				}
+				workflowTask.LastEventID = event.EventId
+				name := event.EventType.String()
+				workflowTask.Label = "Workflow task " + strings.ToLower(strings.TrimPrefix(name, "WorkflowTask"))
+				if name != "WorkflowTaskScheduled" && name != "WorkflowTaskStarted" {
+					workflowTask = nil
+				}
+				continue
+			}
+			entry := &timelineEntry{
+				FirstEventID: event.EventId,
+				LastEventID:  event.EventId,
+				Kind:         kind,
+				Label:        describeEvent(event),
+			}
+			if field, id := relatedEventID(event); id > 0 {
+				entry.Label += fmt.Sprintf(" [%v %v]", field, id)
+			}
+			entry.Command = attributeInt(event, "workflowTaskCompletedEventId") > 0
+			// Links to other executions
+			switch {
+			case event == hist.Events[0]:
+				if pred := h.predecessor(i); pred >= 0 {
+					entry.Link, entry.LinkLabel = timelines[pred], "continued from"
+				} else if parent := h.parent(i); parent >= 0 {
+					entry.Link, entry.LinkLabel = timelines[parent], "parent"
+				}
+			case kind == "child":
+				initiatedID := attributeInt(event, "initiatedEventId")
+				if initiatedID == 0 {
+					initiatedID = event.EventId
+				}
+				if child, ok := children[childKey{i, initiatedID}]; ok {
+					entry.Link, entry.LinkLabel = timelines[child], "child"
+				}
+			case event == hist.Events[len(hist.Events)-1]:
+				if successor, ok := successors[i]; ok {
+					entry.Link, entry.LinkLabel = timelines[successor], "continued as"
+				}
+			}
+			timelines[i].Entries = append(timelines[i].Entries, entry)
+		}
+	}
+	return timelines
+}
+
+// eventKind returns the category of the event for timelines.
+func eventKind(event *history.HistoryEvent) string {
+	name := event.EventType.String()
+	switch {
+	case strings.HasPrefix(name, "WorkflowTask"):
+		return "workflow task"
+	case strings.HasPrefix(name, "ActivityTask"):
+		return "activity"
+	case strings.HasPrefix(name, "Timer"):
+		return "timer"
+	case strings.Contains(name, "Signal"):
+		return "signal"
+	case strings.Contains(name, "Update"):
+		return "update"
+	case strings.Contains(name, "ChildWorkflow"):
+		return "child"
+	case strings.HasPrefix(name, "Marker"):
+		return "marker"
+	case strings.HasPrefix(name, "Nexus"):
+		return "nexus"
+	case strings.HasPrefix(name, "WorkflowExecution"):
+		return "workflow"
+	}
+	return "other"
+}
+
+// relatedEventID returns the name and value of the first event ID attribute
+// that refers to an earlier event the event is for, e.g. the scheduled event of
+// an activity completion.
+func relatedEventID(event *history.HistoryEvent) (string, int64) {
+	for _, name := range []string{"scheduledEventId", "initiatedEventId", "startedEventId"} {
+		if id := attributeInt(event, name); id > 0 {
+			return strings.TrimSuffix(name, "EventId"), id
+		}
+	}
+	return "", 0
+}
+
+// attributeInt returns the value of the top-level integer attribute, or zero if
+// it is not present.
+func attributeInt(event *history.HistoryEvent, name string) int64 {
+	attrsField := attributesField(event.EventType)
+	if attrsField == nil || !event.ProtoReflect().Has(attrsField) {
+		return 0
+	}
+	msg := event.ProtoReflect().Get(attrsField).Message()
+	field := findField(msg.Descriptor(), name)
+	if field == nil || field.Kind() != protoreflect.Int64Kind || !msg.Has(field) {
+		return 0
+	}
+	return msg.Get(field).Int()
+}
+
+func renderText(w io.Writer, timelines []*timeline) error {
+	var b strings.Builder
+	for i, t := range timelines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "Execution %v\n", t.Name)
+		for _, entry := range t.Entries {
+			command := ""
+			if entry.Command {
+				command = "command"
+			}
+			fmt.Fprintf(&b, "  %-7v %-13v %-7v %v\n", entry.Events(), entry.Kind, command, entry.Label)
+			if entry.Link != nil {
+				fmt.Fprintf(&b, "  %-29v -> %v %v\n", "", entry.LinkLabel, entry.Link.Name)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderMermaid(w io.Writer, timelines []*timeline) error {
+	var b strings.Builder
+	nodeID := func(t *timeline, entry *timelineEntry) string {
+		return fmt.Sprintf("e%v_%v", t.Index, entry.FirstEventID)
+	}
+	quote := func(s string) string {
+		return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+	}
+	b.WriteString("flowchart TD\n")
+	for _, t := range timelines {
+		fmt.Fprintf(&b, "  subgraph e%v[%v]\n", t.Index, quote(t.Name))
+		for i, entry := range t.Entries {
+			label := entry.Events() + ": " + entry.Label
+			if entry.Command {
+				label += " (command)"
+			}
+			fmt.Fprintf(&b, "    %v[%v]\n", nodeID(t, entry), quote(label))
+			if i > 0 {
+				fmt.Fprintf(&b, "    %v --> %v\n", nodeID(t, t.Entries[i-1]), nodeID(t, entry))
+			}
+		}
+		b.WriteString("  end\n")
+	}
+	// Links between executions point forward, from parent to child and from an
+	// execution to the one it continued as
+	for _, t := range timelines {
+		for _, entry := range t.Entries {
+			if entry.Link == nil || (entry.LinkLabel != "child" && entry.LinkLabel != "continued as") {
+				continue
+			} else if len(entry.Link.Entries) > 0 {
+				fmt.Fprintf(&b, "  %v -.->|%v| %v\n", nodeID(t, entry), entry.LinkLabel, nodeID(entry.Link, entry.Link.Entries[0]))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
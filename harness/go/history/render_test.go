@@ -0,0 +1,46 @@
+package history
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderText(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, testExecutionSet("").Render(&b, RenderText))
+	out := b.String()
+	assert.Contains(t, out, "Execution Parent\n")
+	assert.Contains(t, out, "  2-4     workflow task         Workflow task completed\n")
+	assert.Contains(t, out, "  5       timer         command TimerStarted(timerId=1)\n")
+	assert.Contains(t, out, "  6       child                 StartChildWorkflowExecutionInitiated(workflowType=Child)\n"+
+		"                                -> child Parent/Child@6\n")
+	assert.Contains(t, out, "-> continued as Parent+continued\n")
+	assert.Contains(t, out, "-> continued from Parent\n")
+	assert.Contains(t, out, "-> continued from Parent/Child@6\n")
+	assert.Contains(t, out, "-> parent Parent\n")
+}
+
+func TestRenderMermaidAndHTML(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, testExecutionSet("").Render(&b, RenderMermaid))
+	out := b.String()
+	assert.True(t, strings.HasPrefix(out, "flowchart TD\n"))
+	assert.Contains(t, out, `  subgraph e0["Parent"]`)
+	assert.Contains(t, out, "    e0_1 --> e0_2\n")
+	assert.Contains(t, out, "  e0_6 -.->|child| e3_1\n")
+	assert.Contains(t, out, "  e0_7 -.->|continued as| e1_1\n")
+
+	b.Reset()
+	require.NoError(t, testExecutionSet("").Render(&b, RenderHTML))
+	out = b.String()
+	assert.Contains(t, out, `<section id="execution-3">`)
+	assert.Contains(t, out, `<a href="#execution-3">Parent/Child@6</a>`)
+	// Self-contained
+	assert.NotContains(t, out, "<script")
+	assert.NotContains(t, out, "<link")
+
+	require.Error(t, testExecutionSet("").Render(&b, "svg"))
+}
@@ -0,0 +1,320 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/history/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// ScrubIgnore clears the field so it is never compared.
+	ScrubIgnore = "ignore"
+	// ScrubNormalize replaces a set field with a fixed value so only whether it
+	// is set is compared.
+	ScrubNormalize = "normalize"
+)
+
+// normalizedString is what string and bytes values are normalized to.
+const normalizedString = "<normalized>"
+
+// CompareConfig is the per-feature history comparison config, present as
+// historyCompare in a feature's config.json.
+type CompareConfig struct {
+	// Rules applied on top of the default run-specific scrubbing.
+	Scrub []ScrubRule `json:"scrub"`
+	// Policies by language for how stored histories of each version are
+	// checked, see VersionCheck.
+	Versions map[string][]VersionPolicy `json:"versions"`
+}
+
+// ScrubRule ignores or normalizes a field on matching events before histories
+// are compared.
+type ScrubRule struct {
+	// Event type the rule applies to, e.g. "ActivityTaskScheduled". If empty,
+	// the rule applies to every event and Path is relative to the event itself
+	// instead of its attributes.
+	EventType string `json:"eventType"`
+	// Dot-separated field path relative to the event's attributes, e.g.
+	// "header.fields.myKey". Fields may use their JSON or proto names. Repeated
+	// fields apply the rest of the path to every element. Map fields take a key
+	// as the next segment, or "*" for every key.
+	Path string `json:"path"`
+	// Either ScrubIgnore (the default) or ScrubNormalize.
+	Action string `json:"action"`
+}
+
+// Validate checks that every rule has a known event type, path, and action,
+// and that every version policy is valid.
+func (c CompareConfig) Validate() error {
+	for _, rule := range c.Scrub {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	for lang, policies := range c.Versions {
+		for _, policy := range policies {
+			if err := policy.Validate(); err != nil {
+				return fmt.Errorf("invalid %v version policy: %w", lang, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks that the rule has a known event type, path, and action.
+func (s ScrubRule) Validate() error {
+	if s.Action != "" && s.Action != ScrubIgnore && s.Action != ScrubNormalize {
+		return fmt.Errorf("scrub rule %v has unknown action %q", s, s.Action)
+	}
+	_, desc, err := s.root()
+	if err != nil {
+		return err
+	}
+	segments := strings.Split(s.Path, ".")
+	for i := 0; i < len(segments); i++ {
+		if desc == nil {
+			return fmt.Errorf("scrub rule %v path continues past a non-message field", s)
+		}
+		field := findField(desc, segments[i])
+		if field == nil {
+			return fmt.Errorf("scrub rule %v has unknown field %q on %v", s, segments[i], desc.FullName())
+		}
+		if field.IsMap() {
+			// Next segment is the key
+			if i++; i == len(segments) {
+				break
+			}
+			field = field.MapValue()
+		}
+		desc = field.Message()
+	}
+	return nil
+}
+
+func (s ScrubRule) String() string {
+	if s.EventType == "" {
+		return s.Path
+	}
+	return s.EventType + ":" + s.Path
+}
+
+// root returns the event type and message descriptor the rule's path is
+// relative to. The event type is unspecified for rules applying to all events.
+func (s ScrubRule) root() (enums.EventType, protoreflect.MessageDescriptor, error) {
+	if s.Path == "" {
+		return 0, nil, fmt.Errorf("scrub rule for event type %q missing path", s.EventType)
+	}
+	eventDesc := (&history.HistoryEvent{}).ProtoReflect().Descriptor()
+	if s.EventType == "" {
+		return enums.EVENT_TYPE_UNSPECIFIED, eventDesc, nil
+	}
+	eventType, err := enums.EventTypeFromString(s.EventType)
+	if err != nil || eventType == enums.EVENT_TYPE_UNSPECIFIED {
+		return 0, nil, fmt.Errorf("scrub rule %v has unknown event type", s)
+	}
+	attrsField := attributesField(eventType)
+	if attrsField == nil {
+		return 0, nil, fmt.Errorf("scrub rule %v event type has no attributes", s)
+	}
+	return eventType, attrsField.Message(), nil
+}
+
+// attributesField returns the attributes field on HistoryEvent for the event
+// type, e.g. activityTaskScheduledEventAttributes for ActivityTaskScheduled.
+func attributesField(eventType enums.EventType) protoreflect.FieldDescriptor {
+	name := eventType.String()
+	name = strings.ToLower(name[:1]) + name[1:] + "EventAttributes"
+	return (&history.HistoryEvent{}).ProtoReflect().Descriptor().Fields().ByJSONName(name)
+}
+
+func findField(desc protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if field := desc.Fields().ByJSONName(name); field != nil {
+		return field
+	}
+	return desc.Fields().ByName(protoreflect.Name(name))
+}
+
+// ApplyScrubRules applies the given rules to every matching event. This is
+// meant to be called after ScrubRunSpecificFields.
+func (h Histories) ApplyScrubRules(rules []ScrubRule) error {
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		eventType, _, _ := rule.root()
+		segments := strings.Split(rule.Path, ".")
+		for _, hist := range h {
+			for _, event := range hist.Events {
+				msg := event.ProtoReflect()
+				if eventType != enums.EVENT_TYPE_UNSPECIFIED {
+					if event.EventType != eventType {
+						continue
+					}
+					attrsField := attributesField(eventType)
+					if !msg.Has(attrsField) {
+						continue
+					}
+					msg = msg.Mutable(attrsField).Message()
+				}
+				scrubPath(msg, segments, rule.Action == ScrubNormalize)
+			}
+		}
+	}
+	return nil
+}
+
+// relationshipFields are the fields Histories.Executions finds the
+// relationships between executions by, as rules whose paths are a single
+// field.
+var relationshipFields = []ScrubRule{
+	{Path: "eventId"},
+	{EventType: "WorkflowExecutionStarted", Path: "workflowType"},
+	{EventType: "WorkflowExecutionStarted", Path: "workflowId"},
+	{EventType: "WorkflowExecutionStarted", Path: "parentWorkflowExecution"},
+	{EventType: "WorkflowExecutionStarted", Path: "parentInitiatedEventId"},
+	{EventType: "WorkflowExecutionStarted", Path: "continuedExecutionRunId"},
+	{EventType: "WorkflowExecutionStarted", Path: "originalExecutionRunId"},
+	{EventType: "WorkflowExecutionStarted", Path: "initiator"},
+	{EventType: "StartChildWorkflowExecutionInitiated", Path: "workflowId"},
+	{EventType: "WorkflowExecutionContinuedAsNew", Path: "newExecutionRunId"},
+	{EventType: "WorkflowExecutionCompleted", Path: "newExecutionRunId"},
+	{EventType: "WorkflowExecutionFailed", Path: "newExecutionRunId"},
+	{EventType: "WorkflowExecutionTimedOut", Path: "newExecutionRunId"},
+}
+
+// ApplyScrubRulesKeepingRelationships applies the given rules like
+// ApplyScrubRules, but keeps the fields relationships between executions are
+// found by so the histories can still be stored and paired with
+// PairExecutions. Those fields are scrubbed again when compared.
+func (h Histories) ApplyScrubRulesKeepingRelationships(rules []ScrubRule) error {
+	unscrubbed := make(Histories, len(h))
+	for i, hist := range h {
+		unscrubbed[i] = proto.Clone(hist).(*history.History)
+	}
+	if err := h.ApplyScrubRules(rules); err != nil {
+		return err
+	}
+	// Scrubbing never adds or removes events, so they are in the same places
+	for i, hist := range h {
+		for j, event := range hist.Events {
+			unscrubbedEvent := unscrubbed[i].Events[j]
+			for _, rule := range relationshipFields {
+				eventType, _, _ := rule.root()
+				msg, unscrubbedMsg := event.ProtoReflect(), unscrubbedEvent.ProtoReflect()
+				if eventType != enums.EVENT_TYPE_UNSPECIFIED {
+					attrsField := attributesField(eventType)
+					if unscrubbedEvent.EventType != eventType || !unscrubbedMsg.Has(attrsField) {
+						continue
+					}
+					msg, unscrubbedMsg = msg.Mutable(attrsField).Message(), unscrubbedMsg.Get(attrsField).Message()
+				}
+				field := findField(unscrubbedMsg.Descriptor(), rule.Path)
+				if unscrubbedMsg.Has(field) {
+					msg.Set(field, unscrubbedMsg.Get(field))
+				} else {
+					msg.Clear(field)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func scrubPath(msg protoreflect.Message, segments []string, normalize bool) {
+	field := findField(msg.Descriptor(), segments[0])
+	if len(segments) == 1 {
+		if !normalize {
+			msg.Clear(field)
+		} else if msg.Has(field) {
+			msg.Set(field, normalizedFieldValue(msg, field))
+		}
+		return
+	} else if !msg.Has(field) {
+		return
+	}
+	switch {
+	case field.IsMap():
+		entries := msg.Mutable(field).Map()
+		var keys []protoreflect.MapKey
+		entries.Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+			if segments[1] == "*" || key.String() == segments[1] {
+				keys = append(keys, key)
+			}
+			return true
+		})
+		for _, key := range keys {
+			if len(segments) == 2 {
+				if !normalize {
+					entries.Clear(key)
+				} else {
+					entries.Set(key, normalizedValue(field.MapValue(), entries.NewValue()))
+				}
+			} else {
+				scrubPath(entries.Mutable(key).Message(), segments[2:], normalize)
+			}
+		}
+	case field.IsList():
+		list := msg.Mutable(field).List()
+		for i := 0; i < list.Len(); i++ {
+			scrubPath(list.Get(i).Message(), segments[1:], normalize)
+		}
+	default:
+		scrubPath(msg.Mutable(field).Message(), segments[1:], normalize)
+	}
+}
+
+// normalizedFieldValue returns the normalized form of a set field, keeping the
+// number of elements for repeated and map fields.
+func normalizedFieldValue(msg protoreflect.Message, field protoreflect.FieldDescriptor) protoreflect.Value {
+	value := msg.NewField(field)
+	switch {
+	case field.IsMap():
+		normalized := value.Map()
+		msg.Get(field).Map().Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+			normalized.Set(key, normalizedValue(field.MapValue(), normalized.NewValue()))
+			return true
+		})
+	case field.IsList():
+		normalized, list := value.List(), msg.Get(field).List()
+		for i := 0; i < list.Len(); i++ {
+			normalized.Append(normalizedValue(field, normalized.NewElement()))
+		}
+	default:
+		value = normalizedValue(field, value)
+	}
+	return value
+}
+
+// normalizedValue returns the fixed value for a single value of the field's
+// kind. Messages are normalized to the given empty value.
+func normalizedValue(field protoreflect.FieldDescriptor, empty protoreflect.Value) protoreflect.Value {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return empty
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(normalizedString)
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(normalizedString))
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.EnumKind:
+		return protoreflect.ValueOfEnum(1)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(1)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(1)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(1)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(1)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(1)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(1)
+	}
+	return empty
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/history/v1"
+)
+
+func TestApplyScrubRules(t *testing.T) {
+	newHist := func(headerValue string, input string) Histories {
+		return Histories{{Events: []*history.HistoryEvent{
+			{
+				EventId:   1,
+				EventType: enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+				Attributes: &history.HistoryEvent_ActivityTaskScheduledEventAttributes{
+					ActivityTaskScheduledEventAttributes: &history.ActivityTaskScheduledEventAttributes{
+						ActivityType: &common.ActivityType{Name: "Foo"},
+						Header: &common.Header{Fields: map[string]*common.Payload{
+							"trace": {Data: []byte(headerValue)},
+							"keep":  {Data: []byte("same")},
+						}},
+						Input: &common.Payloads{Payloads: []*common.Payload{{Data: []byte(input)}}},
+					},
+				},
+			},
+			{
+				EventId:   2,
+				EventType: enums.EVENT_TYPE_TIMER_STARTED,
+				Attributes: &history.HistoryEvent_TimerStartedEventAttributes{
+					TimerStartedEventAttributes: &history.TimerStartedEventAttributes{TimerId: "timer-" + input},
+				},
+			},
+		}}}
+	}
+	rules := []ScrubRule{
+		{EventType: "ActivityTaskScheduled", Path: "header.fields.trace"},
+		{EventType: "ActivityTaskScheduled", Path: "input.payloads.data", Action: ScrubNormalize},
+		{EventType: "TimerStarted", Path: "timer_id", Action: ScrubNormalize},
+	}
+	a, b := newHist("a", "1"), newHist("b", "2")
+	require.False(t, a.Equals(b))
+	require.NoError(t, a.ApplyScrubRules(rules))
+	require.NoError(t, b.ApplyScrubRules(rules))
+	assert.True(t, a.Equals(b))
+
+	attrs := a[0].Events[0].GetActivityTaskScheduledEventAttributes()
+	assert.NotContains(t, attrs.Header.Fields, "trace")
+	assert.Equal(t, "same", string(attrs.Header.Fields["keep"].Data))
+	assert.Equal(t, normalizedString, string(attrs.Input.Payloads[0].Data))
+	assert.Equal(t, normalizedString, a[0].Events[1].GetTimerStartedEventAttributes().TimerId)
+
+	// Normalizing keeps whether a value was set
+	unset := newHist("a", "1")
+	unset[0].Events[1].GetTimerStartedEventAttributes().TimerId = ""
+	require.NoError(t, unset.ApplyScrubRules(rules))
+	assert.False(t, a.Equals(unset))
+}
+
+func TestApplyScrubRulesKeepingRelationships(t *testing.T) {
+	set := testExecutionSet("a-")
+	names := set.Executions()
+	rules := []ScrubRule{
+		{EventType: "WorkflowExecutionStarted", Path: "workflowId"},
+		{EventType: "WorkflowExecutionStarted", Path: "parentWorkflowExecution.runId", Action: ScrubNormalize},
+		{EventType: "StartChildWorkflowExecutionInitiated", Path: "workflowId"},
+		{EventType: "TimerStarted", Path: "timerId"},
+		{Path: "eventId"},
+	}
+	require.NoError(t, set.ApplyScrubRulesKeepingRelationships(rules))
+	assert.Equal(t, names, set.Executions())
+	assert.Empty(t, set[0].Events[4].GetTimerStartedEventAttributes().TimerId)
+	assert.Equal(t, "a-parent-1", set[2].Events[0].GetWorkflowExecutionStartedEventAttributes().ParentWorkflowExecution.RunId)
+
+	// Other sets are still paired with it by relationships
+	aNames, bNames := PairExecutions(set, testExecutionSet("b-"))
+	assert.Equal(t, names, aNames)
+	assert.Equal(t, names, bNames)
+}
+
+func TestScrubRuleValidate(t *testing.T) {
+	assert.NoError(t, ScrubRule{Path: "workerMayIgnore"}.Validate())
+	assert.NoError(t, ScrubRule{EventType: "WorkflowExecutionStarted", Path: "header.fields.*"}.Validate())
+	assert.Error(t, ScrubRule{EventType: "NotAnEvent", Path: "input"}.Validate())
+	assert.Error(t, ScrubRule{EventType: "TimerStarted", Path: "input"}.Validate())
+	assert.Error(t, ScrubRule{EventType: "TimerStarted", Path: "timerId.more"}.Validate())
+	assert.Error(t, ScrubRule{EventType: "TimerStarted", Path: "timerId", Action: "drop"}.Validate())
+	assert.Error(t, ScrubRule{EventType: "TimerStarted"}.Validate())
+}
@@ -1,64 +1,289 @@
 package history
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+const (
+	// FormatJSON stores histories as indented JSON in history.<lang>.<version>.json
+	// files. This is the default.
+	FormatJSON = "json"
+	// FormatJSONGzip stores histories as gzipped JSON in
+	// history.<lang>.<version>.json.gz files.
+	FormatJSONGzip = "json.gz"
+	// FormatBinary stores histories as binary protobuf in
+	// history.<lang>.<version>.binpb files. See Histories.MarshalBinary.
+	FormatBinary = "binpb"
+)
+
+// Languages are all languages that may have stored history, by the name used
+// in history file names.
+var Languages = []string{"go", "java", "ts", "py", "cs", "rb", "php"}
+
+// Formats are all known storage formats. Every one is used as the file
+// extension.
+var Formats = []string{FormatJSON, FormatJSONGzip, FormatBinary}
+
+// ValidateFormat returns an error if the format is not empty or one of Formats.
+func ValidateFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, known := range Formats {
+		if format == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown history format %q, must be one of: %v", format, strings.Join(Formats, " or "))
+}
+
 // StoredSet contains histories by version.
 type StoredSet struct {
 	ByVersion map[string]Histories
+	// Files the histories were loaded from by version, if loaded from storage
+	Files map[string]*StoredFile
 }
 
 // Storage represents a place to store histories.
 type Storage struct {
 	Dir  string
 	Lang string
+	// Format histories are written in. Default is FormatJSON. Histories are read
+	// in every format regardless.
+	Format string
+	// Variant is the name of the feature's run variant the histories are for, or
+	// empty for features without run variants. Histories of a variant are stored
+	// in history.<lang>.<variant>.<version> files. See ValidateVariant and
+	// JoinVariant.
+	Variant string
 }
 
-// Load returns all histories or nil if the directory is not present.
-func (s *Storage) Load() (*StoredSet, error) {
-	set := &StoredSet{ByVersion: map[string]Histories{}}
+// StoredFile is a history file in storage.
+type StoredFile struct {
+	Path    string
+	Variant string
+	Version string
+	Format  string
+}
+
+// VariantCaseSeparator joins the run variant and feature case names of a run
+// that has both into the variant its histories are stored as. ValidateVariant
+// rejects it in either name, so joined names cannot collide.
+const VariantCaseSeparator = "+"
+
+// JoinVariant returns the variant histories of the given run variant and feature
+// case are stored as. Either name may be empty.
+func JoinVariant(variant, featureCase string) string {
+	if variant == "" || featureCase == "" {
+		return variant + featureCase
+	}
+	return variant + VariantCaseSeparator + featureCase
+}
+
+// ValidateVariant returns an error if the run variant or feature case name
+// cannot be used in history file names. The name must not contain path
+// separators, dots, or VariantCaseSeparator, and must not start like a version
+// so it can be told apart from one.
+func ValidateVariant(variant string) error {
+	if variant == "" {
+		return fmt.Errorf("variant name is empty")
+	} else if strings.ContainsAny(variant, `./\`+VariantCaseSeparator) {
+		return fmt.Errorf("variant name %q cannot contain '.', '/', '\\', or '%v'", variant, VariantCaseSeparator)
+	} else if startsWithVersion(variant) {
+		return fmt.Errorf("variant name %q cannot start with a version", variant)
+	}
+	return nil
+}
+
+// startsWithVersion returns whether the string starts with a digit, optionally
+// prefixed with "v".
+func startsWithVersion(s string) bool {
+	s = strings.TrimPrefix(s, "v")
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// Files returns all history files for the language of every variant, or nil if
+// the directory is not present. It is an error for a version of a variant to be
+// stored in multiple formats.
+func (s *Storage) Files() ([]*StoredFile, error) {
 	entries, err := os.ReadDir(s.Dir)
 	if os.IsNotExist(err) {
-		return set, nil
+		return nil, nil
 	} else if err != nil {
 		return nil, err
 	}
+	var files []*StoredFile
+	formatsByVersion := map[string]string{}
+	prefix := "history." + s.Lang + "."
 	for _, entry := range entries {
-		prefix := "history." + s.Lang + "."
 		if !strings.HasPrefix(entry.Name(), prefix) {
 			continue
 		}
-		// Load the JSON and store with version
-		var h Histories
-		file := filepath.Join(s.Dir, entry.Name())
-		if b, err := os.ReadFile(file); err != nil {
-			return nil, fmt.Errorf("failed reading %v: %w", file, err)
-		} else if err = json.Unmarshal(b, &h); err != nil {
-			return nil, fmt.Errorf("failed unmarshaling %v: %w", file, err)
+		file := &StoredFile{Path: filepath.Join(s.Dir, entry.Name()), Format: FileFormat(entry.Name())}
+		file.Version = strings.TrimPrefix(strings.TrimSuffix(entry.Name(), "."+file.Format), prefix)
+		// Anything before the version is the variant
+		if variant, version, ok := strings.Cut(file.Version, "."); ok && !startsWithVersion(file.Version) {
+			file.Variant, file.Version = variant, version
+		}
+		key := file.Variant + "." + file.Version
+		if file.Format == "" {
+			return nil, fmt.Errorf("unknown history format for %v", file.Path)
+		} else if existing, ok := formatsByVersion[key]; ok {
+			return nil, fmt.Errorf("history version %v for %v present as both %v and %v",
+				strings.TrimPrefix(key, "."), s.Lang, existing, file.Format)
 		}
-		set.ByVersion[strings.TrimPrefix(strings.TrimSuffix(entry.Name(), ".json"), prefix)] = h
+		formatsByVersion[key] = file.Format
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Load returns all histories of the configured variant or nil if the directory
+// is not present.
+func (s *Storage) Load() (*StoredSet, error) {
+	set := &StoredSet{ByVersion: map[string]Histories{}, Files: map[string]*StoredFile{}}
+	files, err := s.Files()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.Variant != s.Variant {
+			continue
+		}
+		if set.ByVersion[file.Version], err = ReadFile(file.Path, file.Format); err != nil {
+			return nil, err
+		}
+		set.Files[file.Version] = file
 	}
 	return set, nil
 }
 
-// Store stores the given set of histories.
+// Store stores the given set of histories in the configured format and variant,
+// removing any file for the same version in another format.
 func (s *Storage) Store(set *StoredSet) error {
+	if s.Variant != "" {
+		variant, featureCase, joined := strings.Cut(s.Variant, VariantCaseSeparator)
+		if err := ValidateVariant(variant); err != nil {
+			return err
+		} else if joined {
+			if err := ValidateVariant(featureCase); err != nil {
+				return err
+			}
+		}
+	}
 	// Just go through overwriting not caring if files exist
 	if err := os.MkdirAll(s.Dir, 0755); err != nil {
 		return err
 	}
+	format := s.Format
+	if format == "" {
+		format = FormatJSON
+	}
 	for version, hist := range set.ByVersion {
-		file := filepath.Join(s.Dir, "history."+s.Lang+"."+version+".json")
-		if b, err := json.MarshalIndent(hist, "", "  "); err != nil {
-			return fmt.Errorf("failed marshaling %v: %w", file, err)
-		} else if err = os.WriteFile(file, b, 0644); err != nil {
-			return fmt.Errorf("failed writing %v: %w", file, err)
+		if err := WriteFile(s.fileInFormat(version, format), format, hist); err != nil {
+			return err
+		}
+		for _, other := range Formats {
+			if other == format {
+				continue
+			}
+			otherFile := s.fileInFormat(version, other)
+			if err := os.Remove(otherFile); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed removing %v: %w", otherFile, err)
+			}
 		}
 	}
 	return nil
 }
+
+// File returns the path the given version is stored at in the configured
+// format and variant.
+func (s *Storage) File(version string) string {
+	format := s.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	return s.fileInFormat(version, format)
+}
+
+func (s *Storage) fileInFormat(version, format string) string {
+	name := "history." + s.Lang + "."
+	if s.Variant != "" {
+		name += s.Variant + "."
+	}
+	return filepath.Join(s.Dir, name+version+"."+format)
+}
+
+// FileFormat returns the format of a history file by its extension, or empty
+// if unknown.
+func FileFormat(file string) string {
+	// Check longest extensions first so .json.gz is not mistaken for a version
+	// ending in .json
+	for i := len(Formats) - 1; i >= 0; i-- {
+		if strings.HasSuffix(file, "."+Formats[i]) {
+			return Formats[i]
+		}
+	}
+	return ""
+}
+
+// ReadFile reads histories from a file in the given format.
+func ReadFile(file, format string) (Histories, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %v: %w", file, err)
+	}
+	if format == FormatJSONGzip {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed decompressing %v: %w", file, err)
+		} else if b, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("failed decompressing %v: %w", file, err)
+		}
+	}
+	var h Histories
+	if format == FormatBinary {
+		err = h.UnmarshalBinary(b)
+	} else {
+		err = json.Unmarshal(b, &h)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed unmarshaling %v: %w", file, err)
+	}
+	return h, nil
+}
+
+// WriteFile writes histories to a file in the given format.
+func WriteFile(file, format string, h Histories) error {
+	var b []byte
+	var err error
+	switch format {
+	case FormatBinary:
+		b, err = h.MarshalBinary()
+	case FormatJSONGzip:
+		// Compact JSON compresses a bit better and nobody reads it directly
+		if b, err = json.Marshal(h); err == nil {
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			if _, err = w.Write(b); err == nil {
+				err = w.Close()
+			}
+			b = buf.Bytes()
+		}
+	default:
+		b, err = json.MarshalIndent(h, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed marshaling %v: %w", file, err)
+	} else if err = os.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("failed writing %v: %w", file, err)
+	}
+	return nil
+}
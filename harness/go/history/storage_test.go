@@ -0,0 +1,106 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageFormats(t *testing.T) {
+	hists := Histories{
+		testHistory("WorkflowB"),
+		testHistory("WorkflowA"),
+	}
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			storage := &Storage{Dir: t.TempDir(), Lang: "go", Format: format}
+			require.NoError(t, storage.Store(&StoredSet{ByVersion: map[string]Histories{"v1.2.3": hists}}))
+			_, err := os.Stat(filepath.Join(storage.Dir, "history.go.v1.2.3."+format))
+			require.NoError(t, err)
+
+			// Loading doesn't depend on configured format
+			set, err := (&Storage{Dir: storage.Dir, Lang: "go"}).Load()
+			require.NoError(t, err)
+			require.Len(t, set.ByVersion, 1)
+			hists.Sort()
+			require.True(t, hists.Equals(set.ByVersion["v1.2.3"]))
+		})
+	}
+}
+
+func TestStorageStoreReplacesOtherFormats(t *testing.T) {
+	dir := t.TempDir()
+	set := &StoredSet{ByVersion: map[string]Histories{"v1.2.3": {testHistory("Workflow")}}}
+	require.NoError(t, (&Storage{Dir: dir, Lang: "go"}).Store(set))
+	require.NoError(t, (&Storage{Dir: dir, Lang: "go", Format: FormatBinary}).Store(set))
+	files, err := (&Storage{Dir: dir, Lang: "go"}).Files()
+	require.NoError(t, err)
+	require.Equal(t, []*StoredFile{{
+		Path:    filepath.Join(dir, "history.go.v1.2.3.binpb"),
+		Version: "v1.2.3",
+		Format:  FormatBinary,
+	}}, files)
+
+	// Same version in two formats is an error
+	require.NoError(t, WriteFile(filepath.Join(dir, "history.go.v1.2.3.json.gz"), FormatJSONGzip, set.ByVersion["v1.2.3"]))
+	_, err = (&Storage{Dir: dir, Lang: "go"}).Load()
+	require.Error(t, err)
+}
+
+func TestStorageVariants(t *testing.T) {
+	dir := t.TempDir()
+	unqualified := &StoredSet{ByVersion: map[string]Histories{"v1.2.3": {testHistory("Workflow")}}}
+	enabled := &StoredSet{ByVersion: map[string]Histories{"v1.2.3": {testHistory("EnabledWorkflow")}}}
+	require.NoError(t, (&Storage{Dir: dir, Lang: "go"}).Store(unqualified))
+	require.NoError(t, (&Storage{Dir: dir, Lang: "go", Variant: "polls-enabled"}).Store(enabled))
+	_, err := os.Stat(filepath.Join(dir, "history.go.polls-enabled.v1.2.3.json"))
+	require.NoError(t, err)
+
+	// Files has every variant, Load only the configured one
+	files, err := (&Storage{Dir: dir, Lang: "go"}).Files()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "polls-enabled", files[0].Variant)
+	require.Equal(t, "v1.2.3", files[0].Version)
+	require.Equal(t, "", files[1].Variant)
+	require.Equal(t, "v1.2.3", files[1].Version)
+	set, err := (&Storage{Dir: dir, Lang: "go"}).Load()
+	require.NoError(t, err)
+	require.True(t, unqualified.ByVersion["v1.2.3"].Equals(set.ByVersion["v1.2.3"]))
+	set, err = (&Storage{Dir: dir, Lang: "go", Variant: "polls-enabled"}).Load()
+	require.NoError(t, err)
+	require.True(t, enabled.ByVersion["v1.2.3"].Equals(set.ByVersion["v1.2.3"]))
+	require.Equal(t, filepath.Join(dir, "history.go.polls-enabled.v1.2.3.json"), set.Files["v1.2.3"].Path)
+	set, err = (&Storage{Dir: dir, Lang: "go", Variant: "polls-disabled"}).Load()
+	require.NoError(t, err)
+	require.Empty(t, set.ByVersion)
+
+	// Non-Go versions have no prefix
+	require.NoError(t, (&Storage{Dir: dir, Lang: "java", Variant: "polls-enabled"}).Store(
+		&StoredSet{ByVersion: map[string]Histories{"1.0.6": enabled.ByVersion["v1.2.3"]}}))
+	files, err = (&Storage{Dir: dir, Lang: "java"}).Files()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "polls-enabled", files[0].Variant)
+	require.Equal(t, "1.0.6", files[0].Version)
+
+	// Names that cannot be told apart from a version are rejected
+	require.Error(t, ValidateVariant("v2-enabled"))
+	require.Error(t, ValidateVariant("polls.enabled"))
+	require.Error(t, ValidateVariant("polls/enabled"))
+	require.NoError(t, ValidateVariant("cancel-worker-polls-enabled"))
+	require.Error(t, ValidateVariant("polls+enabled"))
+
+	// Variant and case names are joined so they cannot collide, e.g. variant
+	// "a-b" and variant "a" with case "b"
+	require.Equal(t, "a-b", JoinVariant("a-b", ""))
+	require.Equal(t, "a+b", JoinVariant("a", "b"))
+	require.Equal(t, "b", JoinVariant("", "b"))
+	require.NoError(t, (&Storage{Dir: dir, Lang: "java", Variant: JoinVariant("polls", "enabled")}).Store(enabled))
+	set, err = (&Storage{Dir: dir, Lang: "java", Variant: "polls+enabled"}).Load()
+	require.NoError(t, err)
+	require.Len(t, set.ByVersion, 1)
+	require.Error(t, (&Storage{Dir: dir, Lang: "java", Variant: "polls+v2"}).Store(enabled))
+}
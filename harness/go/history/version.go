@@ -0,0 +1,136 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// VersionCheckMatch requires the current history to equal the stored history
+	// once both are scrubbed. Replaying languages also replay it. This is the
+	// default.
+	VersionCheckMatch = "match"
+	// VersionCheckReplay only replays the stored history in languages that
+	// replay, it is not compared with the current history.
+	VersionCheckReplay = "replay"
+	// VersionCheckIgnore neither compares nor replays the stored history.
+	VersionCheckIgnore = "ignore"
+)
+
+// VersionPolicy decides how stored histories of some versions are checked.
+type VersionPolicy struct {
+	// Range of stored history versions this applies to, e.g. "<1.20.0".
+	Stored string `json:"stored"`
+	// Optional range of current SDK versions this applies to, e.g. ">=1.20.0".
+	// If set, the policy does not apply when the current version is unknown.
+	Current string `json:"current"`
+	// One of VersionCheckMatch, VersionCheckReplay, or VersionCheckIgnore.
+	Check string `json:"check"`
+}
+
+// VersionCheck returns how the stored history of the given language and
+// version must be checked when running the given current version. The first
+// policy for the language whose ranges contain both versions applies, otherwise
+// it is VersionCheckMatch. The current version may be empty if unknown.
+func (c CompareConfig) VersionCheck(lang, current, stored string) string {
+	for _, policy := range c.Versions[lang] {
+		if policy.Current != "" && !VersionRange(policy.Current).Contains(current) {
+			continue
+		}
+		if VersionRange(policy.Stored).Contains(stored) {
+			return policy.Check
+		}
+	}
+	return VersionCheckMatch
+}
+
+// Validate checks that the policy has valid ranges and a known check.
+func (v VersionPolicy) Validate() error {
+	if v.Check != VersionCheckMatch && v.Check != VersionCheckReplay && v.Check != VersionCheckIgnore {
+		return fmt.Errorf("version policy has unknown check %q", v.Check)
+	} else if v.Stored == "" {
+		return fmt.Errorf("version policy missing stored range")
+	} else if err := VersionRange(v.Stored).Validate(); err != nil {
+		return err
+	} else if v.Current != "" {
+		return VersionRange(v.Current).Validate()
+	}
+	return nil
+}
+
+// VersionRange is a semver range. It is a set of alternatives separated by
+// "||", each a space-separated set of comparisons that must all hold. A
+// comparison is a version optionally prefixed with one of =, <, <=, >, or >=,
+// e.g. ">=1.20.0 <1.30.0 || 2.0.0". A leading "v" on versions is optional.
+type VersionRange string
+
+// Validate checks that every version in the range is valid semver.
+func (v VersionRange) Validate() error {
+	for _, alternative := range strings.Split(string(v), "||") {
+		comparisons := strings.Fields(alternative)
+		if len(comparisons) == 0 {
+			return fmt.Errorf("version range %q has empty alternative", v)
+		}
+		for _, comparison := range comparisons {
+			_, version := splitComparison(comparison)
+			if !semver.IsValid(version) {
+				return fmt.Errorf("version range %q has invalid version %q", v, comparison)
+			}
+		}
+	}
+	return nil
+}
+
+// Contains returns whether the version is in the range. Invalid versions and
+// ranges contain nothing.
+func (v VersionRange) Contains(version string) bool {
+	version = canonicalVersion(version)
+	if !semver.IsValid(version) {
+		return false
+	}
+	for _, alternative := range strings.Split(string(v), "||") {
+		comparisons := strings.Fields(alternative)
+		matches := len(comparisons) > 0
+		for _, comparison := range comparisons {
+			op, against := splitComparison(comparison)
+			if !semver.IsValid(against) {
+				return false
+			}
+			cmp := semver.Compare(version, against)
+			switch op {
+			case "<":
+				matches = matches && cmp < 0
+			case "<=":
+				matches = matches && cmp <= 0
+			case ">":
+				matches = matches && cmp > 0
+			case ">=":
+				matches = matches && cmp >= 0
+			default:
+				matches = matches && cmp == 0
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func splitComparison(comparison string) (op, version string) {
+	for _, prefix := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(comparison, prefix) {
+			return prefix, canonicalVersion(strings.TrimPrefix(comparison, prefix))
+		}
+	}
+	return "=", canonicalVersion(comparison)
+}
+
+func canonicalVersion(version string) string {
+	if version != "" && !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
@@ -0,0 +1,42 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionRangeContains(t *testing.T) {
+	r := VersionRange(">=1.20.0 <1.30.0 || v2.0.0")
+	require.NoError(t, r.Validate())
+	require.True(t, r.Contains("v1.20.0"))
+	require.True(t, r.Contains("1.29.1"))
+	require.False(t, r.Contains("v1.30.0"))
+	require.False(t, r.Contains("1.19.9"))
+	require.True(t, r.Contains("2.0.0"))
+	require.False(t, r.Contains(""))
+	require.False(t, r.Contains("manual"))
+
+	require.Error(t, VersionRange(">=1.x").Validate())
+	require.Error(t, VersionRange("1.0.0 ||").Validate())
+}
+
+func TestVersionCheck(t *testing.T) {
+	config := CompareConfig{Versions: map[string][]VersionPolicy{
+		"go": {
+			{Current: ">=1.20.0", Stored: "<1.20.0", Check: VersionCheckReplay},
+			{Stored: "1.5.0", Check: VersionCheckIgnore},
+		},
+	}}
+	require.NoError(t, config.Validate())
+	require.Equal(t, VersionCheckReplay, config.VersionCheck("go", "v1.21.0", "v1.19.0"))
+	require.Equal(t, VersionCheckMatch, config.VersionCheck("go", "v1.21.0", "v1.20.0"))
+	// Current range does not apply, falls through to next policy
+	require.Equal(t, VersionCheckIgnore, config.VersionCheck("go", "v1.10.0", "v1.5.0"))
+	require.Equal(t, VersionCheckIgnore, config.VersionCheck("go", "", "v1.5.0"))
+	require.Equal(t, VersionCheckMatch, config.VersionCheck("go", "", "v1.19.0"))
+	require.Equal(t, VersionCheckMatch, config.VersionCheck("java", "1.21.0", "1.5.0"))
+
+	config.Versions["go"] = append(config.Versions["go"], VersionPolicy{Stored: "<1.0.0", Check: "skip"})
+	require.Error(t, config.Validate())
+}
@@ -199,6 +199,8 @@ public class Main implements Runnable {
           String jsonInString =
               mapper.writeValueAsString(new SummaryEntry(feature.dir, outcome.toString(), message));
           writer.write(jsonInString + "\n");
+          // Flushed per feature so the runner can stop this harness at the first failure
+          writer.flush();
         } catch (IOException e) {
           throw new RuntimeException(e);
         }